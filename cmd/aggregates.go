@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// groupStats summarizes the requests in one group, such as a route or an
+// edge. It is what the routes view shows and what --summary-file exports.
+type groupStats struct {
+	// By is what the requests were grouped by: route, path or edge.
+	By        string  `json:"by"`
+	Group     string  `json:"group"`
+	Count     int     `json:"count"`
+	Successes int     `json:"successes"`
+	P50Ms     float64 `json:"p50Ms"`
+	P95Ms     float64 `json:"p95Ms"`
+	P99Ms     float64 `json:"p99Ms"`
+
+	p50, p95, p99 time.Duration
+}
+
+// successRate is the percentage of the group's requests that succeeded.
+func (s groupStats) successRate() float64 {
+	return 100 * float64(s.Successes) / float64(s.Count)
+}
+
+// aggregate groups events by key and summarizes each group, busiest first.
+// Requests that never ended are counted but have no latency to summarize.
+func aggregate(events []pkg.Stream, by string, key func(pkg.Stream) string) []groupStats {
+	counts := make(map[string]int)
+	latencies := make(map[string][]time.Duration)
+	successes := make(map[string]int)
+	for _, req := range events {
+		group := key(req)
+		counts[group]++
+		if req.RspEnd != nil {
+			latencies[group] = append(latencies[group], latencyDuration(req))
+		}
+		if isSuccess(req) {
+			successes[group]++
+		}
+	}
+	stats := make([]groupStats, 0, len(counts))
+	for group, count := range counts {
+		durations := latencies[group]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		s := groupStats{
+			By:        by,
+			Group:     group,
+			Count:     count,
+			Successes: successes[group],
+			p50:       percentile(durations, 0.5),
+			p95:       percentile(durations, 0.95),
+			p99:       percentile(durations, 0.99),
+		}
+		s.P50Ms = milliseconds(s.p50)
+		s.P95Ms = milliseconds(s.p95)
+		s.P99Ms = milliseconds(s.p99)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Group < stats[j].Group
+	})
+	return stats
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// aggregates summarizes the capture by route, by path, and by edge.
+func (el *eventLog) aggregates() []groupStats {
+	stats := aggregate(el.events, "route", routeName)
+	stats = append(stats, aggregate(el.events, "path", func(req pkg.Stream) string {
+		return req.ReqInit.GetPath()
+	})...)
+	return append(stats, aggregate(el.events, "edge", func(req pkg.Stream) string {
+		return el.edgePeer(req.Event.GetSource(), req.Event.GetSourceMeta()) + " → " +
+			el.edgePeer(req.Event.GetDestination(), req.Event.GetDestinationMeta())
+	})...)
+}
+
+// writeAggregates writes stats to path, as JSON if it ends in .json and as CSV
+// otherwise.
+func writeAggregates(path string, stats []groupStats) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".json" {
+		err = writeAggregatesJSON(file, stats)
+	} else {
+		err = writeAggregatesCSV(file, stats)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeAggregatesJSON(w io.Writer, stats []groupStats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
+
+func writeAggregatesCSV(w io.Writer, stats []groupStats) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"by", "group", "count", "success_rate", "p50_ms", "p95_ms", "p99_ms"})
+	for _, s := range stats {
+		writer.Write([]string{
+			s.By,
+			s.Group,
+			strconv.Itoa(s.Count),
+			strconv.FormatFloat(s.successRate(), 'f', 1, 64),
+			strconv.FormatFloat(s.P50Ms, 'f', 3, 64),
+			strconv.FormatFloat(s.P95Ms, 'f', 3, 64),
+			strconv.FormatFloat(s.P99Ms, 'f', 3, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportAggregates writes the summary of the capture so far to a CSV file in
+// the working directory and reports where it went in the status line.
+func (el *eventLog) exportAggregates() {
+	path := fmt.Sprintf("tapshark-%s-summary.csv", time.Now().Format("20060102-150405"))
+	if err := writeAggregates(path, el.aggregates()); err != nil {
+		el.notice = fmt.Sprintf("summary export failed: %v", err)
+	} else {
+		el.notice = fmt.Sprintf("summary written to %s", path)
+	}
+	el.updateStatus()
+}
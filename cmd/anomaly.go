@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+)
+
+// bodyWarningMarker follows the verb of requests that have a body their
+// method doesn't usually carry.
+const bodyWarningMarker = "⚠"
+
+// bodilessMethods are the methods whose requests shouldn't have a body: the
+// HTTP spec gives a body no meaning for them, and many servers and proxies
+// ignore or reject one.
+var bodilessMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"DELETE":  true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"CONNECT": true,
+}
+
+// bodyWarning describes why req looks like a client bug, if it is a request
+// for a bodiless method that says it has a body. Tap doesn't report how many
+// bytes a request sent, so its content-length and transfer-encoding headers
+// are relied on.
+func bodyWarning(req pkg.Stream) (string, bool) {
+	verb := method(req)
+	if !bodilessMethods[verb] {
+		return "", false
+	}
+	if size := requestBodySize(req); size > 0 {
+		return fmt.Sprintf("%s requests don't usually have a body; this one has %d bytes", verb, size), true
+	}
+	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+		if strings.EqualFold(header.GetName(), "transfer-encoding") {
+			return fmt.Sprintf("%s requests don't usually have a body; this one is sent with transfer-encoding %s", verb, header.GetValueStr()), true
+		}
+	}
+	return "", false
+}
+
+// verbColor shows the verb of requests with an unexpected body in the
+// warning color.
+func verbColor(el *eventLog, req pkg.Stream) tcell.Color {
+	if _, ok := bodyWarning(req); ok {
+		return el.theme.clientError
+	}
+	return el.rowColor(req)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+
+	netPb "github.com/linkerd/linkerd2/controller/gen/common/net"
+	"github.com/linkerd/linkerd2/pkg/addr"
+)
+
+// An anonymizer replaces pod names, CronJob and Job names, and IP addresses
+// with pseudonyms that stay the same for the rest of the session. A nil
+// anonymizer leaves everything as it is.
+type anonymizer struct {
+	pods map[string]string
+	ips  map[string]string
+	// workloads holds the pseudonyms of each kind of workload.
+	workloads map[string]map[string]string
+}
+
+func newAnonymizer() *anonymizer {
+	return &anonymizer{
+		pods:      map[string]string{},
+		ips:       map[string]string{},
+		workloads: map[string]map[string]string{},
+	}
+}
+
+func (a *anonymizer) pod(name string) string {
+	if a == nil || name == "" {
+		return name
+	}
+	return pseudonym(a.pods, "pod", name)
+}
+
+// workload replaces the name of a workload of the given kind, such as job.
+func (a *anonymizer) workload(kind, name string) string {
+	if a == nil || name == "" {
+		return name
+	}
+	names, ok := a.workloads[kind]
+	if !ok {
+		names = map[string]string{}
+		a.workloads[kind] = names
+	}
+	return pseudonym(names, kind, name)
+}
+
+func (a *anonymizer) ip(ip string) string {
+	if a == nil || ip == "" {
+		return ip
+	}
+	return pseudonym(a.ips, "ip", ip)
+}
+
+// address formats a peer address as ip:port with the IP replaced.
+func (a *anonymizer) address(tcpAddress *netPb.TcpAddress) string {
+	return a.hostPort(addr.PublicAddressToString(tcpAddress))
+}
+
+// hostPort replaces the IP of an ip:port.
+func (a *anonymizer) hostPort(address string) string {
+	if a == nil {
+		return address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return a.ip(address)
+	}
+	return net.JoinHostPort(a.ip(host), port)
+}
+
+// label anonymizes the value of a tap metadata label if it names a pod, or
+// the CronJob or Job that owns one.
+func (a *anonymizer) label(key, value string) string {
+	switch key {
+	case "pod":
+		return a.pod(value)
+	case "cronjob":
+		return a.workload("cronjob", value)
+	case "k8s_job":
+		return a.workload("job", value)
+	}
+	return value
+}
+
+// labels anonymizes a copy of a peer's metadata labels.
+func (a *anonymizer) labels(labels map[string]string) map[string]string {
+	if a == nil || labels == nil {
+		return labels
+	}
+	anonymized := make(map[string]string, len(labels))
+	for k, v := range labels {
+		anonymized[k] = a.label(k, v)
+	}
+	return anonymized
+}
+
+// record anonymizes the peers and authority of a record written with
+// --output, as the UI does.
+func (a *anonymizer) record(record streamRecord) streamRecord {
+	if a == nil {
+		return record
+	}
+	record.Source = a.hostPort(record.Source)
+	record.SourceMeta = a.labels(record.SourceMeta)
+	record.Destination = a.hostPort(record.Destination)
+	record.DestinationMeta = a.labels(record.DestinationMeta)
+	record.Authority = a.authority(record.Authority)
+	return record
+}
+
+// authority anonymizes an :authority that addresses a peer by IP.
+func (a *anonymizer) authority(authority string) string {
+	if a == nil {
+		return authority
+	}
+	host, port := splitAuthority(authority)
+	if net.ParseIP(host) == nil {
+		return authority
+	}
+	if port == "" {
+		return a.ip(host)
+	}
+	return net.JoinHostPort(a.ip(host), port)
+}
+
+func pseudonym(names map[string]string, prefix, name string) string {
+	if p, ok := names[name]; ok {
+		return p
+	}
+	p := fmt.Sprintf("%s-%d", prefix, len(names)+1)
+	names[name] = p
+	return p
+}
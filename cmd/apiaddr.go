@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	"k8s.io/client-go/rest"
+)
+
+const unixScheme = "unix://"
+
+// unixSocketPath returns the path of the socket named by an --api-addr of the
+// form unix:///path/to/socket.
+func unixSocketPath(apiAddr string) (string, bool) {
+	if !strings.HasPrefix(apiAddr, unixScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(apiAddr, unixScheme), true
+}
+
+// newKubernetesAPI returns a client for the Kubernetes API, which the tap
+// stream is also read through. If --api-addr names a Unix socket, such as one
+// served by `kubectl proxy --unix-socket`, every request is sent over it
+// instead and the kubeconfig isn't used; the proxy is expected to
+// authenticate them.
+func newKubernetesAPI(options *options) (*k8s.KubernetesAPI, error) {
+	path, ok := unixSocketPath(options.apiAddr)
+	if !ok {
+		return k8s.NewAPI(options.kubeconfigPath, options.kubeContext, options.impersonate, options.impersonateGroup, options.kubeTimeout)
+	}
+	config := &rest.Config{
+		// The host is only used to build request URLs; every connection
+		// is made to the socket.
+		Host: "http://localhost",
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}
+	return k8s.NewAPIForConfig(config, options.impersonate, options.impersonateGroup, options.kubeTimeout)
+}
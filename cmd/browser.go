@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/rivo/tview"
+)
+
+// defaultOpenTemplate opens the request itself.
+const defaultOpenTemplate = "{scheme}://{authority}{path}"
+
+// openPlaceholders are the placeholders an --open-template may use, and how
+// each is filled in from a request. Values that may hold anything are
+// escaped for use in a query string.
+var openPlaceholders = map[string]func(req pkg.Stream) string{
+	"{scheme}": func(req pkg.Stream) string {
+		return strings.ToLower(scheme(req))
+	},
+	"{authority}": func(req pkg.Stream) string {
+		return req.ReqInit.GetAuthority()
+	},
+	"{path}": func(req pkg.Stream) string {
+		return req.ReqInit.GetPath()
+	},
+	"{method}": method,
+	"{status}": status,
+	"{request_id}": func(req pkg.Stream) string {
+		return url.QueryEscape(requestHeader(req, "x-request-id"))
+	},
+	"{trace_id}": func(req pkg.Stream) string {
+		id, _ := traceID(req)
+		// The trace ID is given without the header it came from.
+		return url.QueryEscape(id[strings.Index(id, "=")+1:])
+	},
+}
+
+// validateOpenTemplate checks that template only uses known placeholders.
+func validateOpenTemplate(template string) error {
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return fmt.Errorf("invalid --open-template %q: unterminated placeholder", template)
+		}
+		placeholder := rest[start : start+end+1]
+		if _, ok := openPlaceholders[placeholder]; !ok {
+			return fmt.Errorf("invalid --open-template %q: unknown placeholder %s", template, placeholder)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// expandOpenTemplate fills in the placeholders of template from req.
+func expandOpenTemplate(template string, req pkg.Stream) string {
+	var replacements []string
+	for placeholder, value := range openPlaceholders {
+		if strings.Contains(template, placeholder) {
+			replacements = append(replacements, placeholder, value(req))
+		}
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// requestHeader returns the value of the named request header, or nothing.
+func requestHeader(req pkg.Stream, name string) string {
+	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+		if strings.EqualFold(header.GetName(), name) {
+			return header.GetValueStr()
+		}
+	}
+	return ""
+}
+
+// openInBrowser opens the --open-template URL for the request shown in the
+// details pane with the desktop's default handler.
+func (el *eventLog) openInBrowser() {
+	if el.detailEvent < 0 {
+		return
+	}
+	target := expandOpenTemplate(el.openTemplate, el.events[el.detailEvent])
+	// The URL is taken from the request, and may hold square brackets that
+	// the status line would take for style tags.
+	if err := openURL(target); err != nil {
+		el.notice = tview.Escape(fmt.Sprintf("couldn't open %s: %v", target, err))
+	} else {
+		el.notice = "opened " + tview.Escape(target)
+	}
+	el.updateStatus()
+}
+
+// openURL starts the platform's opener for target without waiting for it,
+// so that the UI isn't blocked while a browser starts.
+func openURL(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// A captureRecord describes how a capture was taken. It is written as the
+// first line of a capture, before any streamRecord, and is told apart from
+// them by its Capture field.
+type captureRecord struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Capture       *captureMetadata `json:"capture"`
+}
+
+type captureMetadata struct {
+	// Targets are the resources that were tapped, as namespace/resource,
+	// followed by the destination resource if --to was given.
+	Targets []string `json:"targets"`
+	// Args are the command line arguments, which include every filter.
+	Args []string `json:"args"`
+	// Context is the kubeconfig context the capture was taken in. It is
+	// omitted if the API was reached through a socket.
+	Context string `json:"context,omitempty"`
+	// Version is the version of tapshark that took the capture.
+	Version string    `json:"version"`
+	Start   time.Time `json:"start"`
+}
+
+// newCaptureRecord describes a capture of targets that starts now.
+func newCaptureRecord(options *options, targets []tapPkg.TapRequestParams) captureRecord {
+	metadata := &captureMetadata{
+		Args:    os.Args[1:],
+		Context: kubeContextName(options),
+		Version: version(),
+		Start:   time.Now(),
+	}
+	for _, target := range targets {
+		name := target.Namespace + "/" + target.Resource
+		if target.ToResource != "" {
+			name += " to " + target.ToNamespace + "/" + target.ToResource
+		}
+		metadata.Targets = append(metadata.Targets, name)
+	}
+	return captureRecord{SchemaVersion: recordSchemaVersion, Capture: metadata}
+}
+
+// kubeContextName returns the kubeconfig context that the API is reached
+// through, or nothing if it isn't known.
+func kubeContextName(options *options) string {
+	if _, ok := unixSocketPath(options.apiAddr); ok {
+		return ""
+	}
+	if options.kubeContext != "" {
+		return options.kubeContext
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = options.kubeconfigPath
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return ""
+	}
+	return config.CurrentContext
+}
+
+// version returns the module version tapshark was built from, which is only
+// known when it was installed with `go install`.
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// describe summarizes the metadata for the status line, with the start time in
+// location.
+func (m *captureMetadata) describe(location *time.Location) string {
+	parts := []string{fmt.Sprintf("captured %s", m.Start.In(location).Format("2006-01-02 15:04:05 MST"))}
+	if len(m.Targets) > 0 {
+		parts = append(parts, "from "+strings.Join(m.Targets, ", "))
+	}
+	if m.Context != "" {
+		parts = append(parts, "in context "+m.Context)
+	}
+	parts = append(parts, "by tapshark "+m.Version)
+	return strings.Join(parts, " ")
+}
+
+// showCapture shows how a replayed capture was taken: its command line in the
+// title, where the live command line would be, and the rest on the status
+// line.
+func (el *eventLog) showCapture(metadata *captureMetadata) {
+	el.title = strings.Join(append([]string{"tapshark"}, metadata.Args...), " ")
+	el.updateTitle()
+	el.notice = metadata.describe(el.location)
+	el.updateStatus()
+}
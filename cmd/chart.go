@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/rivo/tview"
+)
+
+const (
+	// latencyChartBuckets is the most time windows the chart shows, one per
+	// character. Windows widen as the capture grows so that it always fits.
+	latencyChartBuckets = 60
+	latencyChartHeight  = 12
+	// latencyChartMinBucket is the narrowest window.
+	latencyChartMinBucket = time.Second
+)
+
+// renderLatencyChart plots the p50 and p99 latency of each time window since
+// the capture started, oldest on the left.
+func renderLatencyChart(table *tview.Table, events []pkg.Stream) {
+	bucketMs, n := timeBuckets(events)
+	buckets := make([][]time.Duration, n)
+	for _, req := range events {
+		if req.RspEnd == nil {
+			continue
+		}
+		i := req.TimestampMs / bucketMs
+		buckets[i] = append(buckets[i], latencyDuration(req))
+	}
+	p50s := make([]time.Duration, len(buckets))
+	p99s := make([]time.Duration, len(buckets))
+	var max time.Duration
+	for i, durations := range buckets {
+		sort.Slice(durations, func(a, b int) bool { return durations[a] < durations[b] })
+		p50s[i] = percentile(durations, 0.5)
+		p99s[i] = percentile(durations, 0.99)
+		if p99s[i] > max {
+			max = p99s[i]
+		}
+	}
+
+	setHeader(table, pad("LATENCY"), fmt.Sprintf("* p99  o p50  (%s per column)", time.Duration(bucketMs)*time.Millisecond))
+	level := func(d time.Duration) int {
+		if max == 0 {
+			return 0
+		}
+		return int((int64(d)*(latencyChartHeight-1) + int64(max)/2) / int64(max))
+	}
+	for row := 0; row < latencyChartHeight; row++ {
+		lvl := latencyChartHeight - 1 - row
+		var line strings.Builder
+		for i, durations := range buckets {
+			switch {
+			case len(durations) == 0:
+				line.WriteByte(' ')
+			case level(p99s[i]) == lvl:
+				line.WriteByte('*')
+			case level(p50s[i]) == lvl:
+				line.WriteByte('o')
+			default:
+				line.WriteByte(' ')
+			}
+		}
+		// Only the top, middle and bottom of the axis are labelled.
+		var label string
+		if lvl == 0 || lvl == latencyChartHeight-1 || lvl == (latencyChartHeight-1)/2 {
+			label = axisLabel(max * time.Duration(lvl) / (latencyChartHeight - 1))
+		}
+		table.SetCellSimple(row+1, 0, pad(label))
+		table.SetCellSimple(row+1, 1, line.String())
+	}
+
+	table.SetCellSimple(latencyChartHeight+1, 0, "")
+	table.SetCellSimple(latencyChartHeight+1, 1, timeAxis(bucketMs, len(buckets)))
+	truncateRows(table, latencyChartHeight+2)
+}
+
+// timeBuckets divides the time since the capture started into at most
+// latencyChartBuckets windows of bucketMs each, covering every event. It
+// returns the width of each window and how many there are.
+func timeBuckets(events []pkg.Stream) (bucketMs uint64, n int) {
+	var end uint64
+	for _, req := range events {
+		if req.TimestampMs > end {
+			end = req.TimestampMs
+		}
+	}
+	bucketMs = uint64(latencyChartMinBucket.Milliseconds())
+	for end/bucketMs >= latencyChartBuckets {
+		bucketMs *= 2
+	}
+	return bucketMs, int(end/bucketMs) + 1
+}
+
+// timeAxis labels either end of n windows of bucketMs, in seconds since the
+// start.
+func timeAxis(bucketMs uint64, n int) string {
+	first := formatTimestamp(0)
+	last := formatTimestamp(uint64(n) * bucketMs)
+	gap := n - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	return first + strings.Repeat(" ", gap) + last
+}
+
+// axisLabel rounds d to about three significant figures.
+func axisLabel(d time.Duration) string {
+	unit := time.Nanosecond
+	for d/unit >= 1000 {
+		unit *= 10
+	}
+	return d.Round(unit).String()
+}
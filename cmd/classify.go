@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/adleong/tapshark/pkg"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+)
+
+const (
+	classSuccess = "success"
+	classFailure = "failure"
+
+	// proxyErrorHeader is added by the proxy to responses it generated
+	// itself because of an error.
+	proxyErrorHeader = "l5d-proxy-error"
+)
+
+// classify decides whether a request succeeded the way the proxy's default
+// response classifier does, so that it agrees with the success rates reported
+// by linkerd viz. It returns the class and, for failures, the reason.
+//
+// A request fails if its stream was reset or ended without a response, if it
+// ended with a non-OK gRPC status (in the trailers, or in the headers of a
+// trailers-only response), or if it had a 5xx status.
+func classify(req pkg.Stream) (string, string) {
+	switch eos := req.RspEnd.GetEos().GetEnd().(type) {
+	case *metricsPb.Eos_ResetErrorCode:
+		return classFailure, fmt.Sprintf("reset with %s", resetReason(eos.ResetErrorCode))
+	case *metricsPb.Eos_GrpcStatusCode:
+		if eos.GrpcStatusCode != 0 {
+			return classFailure, fmt.Sprintf("gRPC status %s", grpcStatusName(eos.GrpcStatusCode))
+		}
+		return classSuccess, ""
+	}
+	if req.RspInit == nil {
+		return classFailure, "no response"
+	}
+	for _, header := range req.RspInit.GetHeaders().GetHeaders() {
+		switch header.GetName() {
+		case "grpc-status":
+			if code, err := strconv.ParseUint(header.GetValueStr(), 10, 32); err == nil && code != 0 {
+				return classFailure, fmt.Sprintf("gRPC status %s", grpcStatusName(uint32(code)))
+			}
+		case proxyErrorHeader:
+			return classFailure, fmt.Sprintf("proxy error: %s", header.GetValueStr())
+		}
+	}
+	if status := req.RspInit.GetHttpStatus(); status >= 500 {
+		return classFailure, fmt.Sprintf("HTTP status %d", status)
+	}
+	return classSuccess, ""
+}
+
+func isSuccess(req pkg.Stream) bool {
+	class, _ := classify(req)
+	return class == classSuccess
+}
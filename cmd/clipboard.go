@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+	"github.com/rivo/tview"
+)
+
+// showHeaderPicker lists the headers and trailers of the request shown in the
+// details pane. Choosing one copies its value to the clipboard.
+func (el *eventLog) showHeaderPicker() {
+	if el.detailEvent < 0 {
+		return
+	}
+	req := el.events[el.detailEvent]
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(" Copy header value ")
+	add := func(kind string, headers *metricsPb.Headers) {
+		for _, header := range headers.GetHeaders() {
+			name := header.GetName()
+			value := header.GetValueStr()
+			label := fmt.Sprintf("%s %s: %s", kind, name, value)
+			list.AddItem(tview.Escape(label), "", 0, func() {
+				copyToClipboard(value)
+				el.notice = fmt.Sprintf("copied %s to the clipboard", name)
+				el.closeOverlay()
+			})
+		}
+	}
+	add("request", req.ReqInit.GetHeaders())
+	add("response", req.RspInit.GetHeaders())
+	add("trailer", req.RspEnd.GetTrailers())
+	if list.GetItemCount() == 0 {
+		return
+	}
+	list.SetDoneFunc(el.closeOverlay)
+
+	el.showOverlay(list)
+}
+
+// copyToClipboard asks the terminal to put text on the system clipboard using
+// the OSC 52 escape sequence, which works over SSH but isn't supported by
+// every terminal.
+func copyToClipboard(text string) {
+	fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
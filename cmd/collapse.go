@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/rivo/tview"
+)
+
+// A collapsedRow is a row that later identical requests are counted in.
+type collapsedRow struct {
+	event int
+	// lastMs is when the most recent request counted in the row arrived.
+	lastMs uint64
+}
+
+// collapse counts req, which is events[idx], in the row of an identical
+// request seen within the dedup window, if there is one. Requests are
+// identical if they have the same peers, verb, path, and status. It returns
+// the index of the event whose row req is shown in, which is idx if req gets
+// a row of its own.
+func (el *eventLog) collapse(idx int, req pkg.Stream) int {
+	if el.dedupWindow <= 0 {
+		el.repeats = append(el.repeats, 0)
+		return idx
+	}
+	from, pod, to := el.fromPodTo(req)
+	key := strings.Join([]string{from, pod, to, req.ReqInit.GetMethod().GetRegistered().String(), req.ReqInit.GetPath(), status(req)}, "\x00")
+
+	row, ok := el.collapsed[key]
+	// A key expires once the window has passed without a matching request,
+	// so that the next one gets a row of its own.
+	if !ok || req.TimestampMs > row.lastMs+uint64(el.dedupWindow.Milliseconds()) {
+		el.collapsed[key] = &collapsedRow{event: idx, lastMs: req.TimestampMs}
+		el.repeats = append(el.repeats, 0)
+		return idx
+	}
+	if req.TimestampMs > row.lastMs {
+		row.lastMs = req.TimestampMs
+	}
+	el.repeats[row.event]++
+	// A collapsed request has no row of its own.
+	el.repeats = append(el.repeats, -1)
+	return row.event
+}
+
+// refreshEvent redraws the row showing events[idx], if there is one.
+func (el *eventLog) refreshEvent(idx int) {
+	for _, t := range []struct {
+		table *tview.Table
+		rows  []tableRow
+	}{{el.table, el.rows}, {el.outbound, el.outboundRows}} {
+		for i, row := range t.rows {
+			if row.marker == nil && row.event == idx {
+				el.setRow(t.table, i+1, row)
+				return
+			}
+		}
+	}
+}
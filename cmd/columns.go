@@ -0,0 +1,348 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+)
+
+type column struct {
+	header string
+	// padded columns are rendered with a space on either side to separate
+	// them from their neighbours.
+	padded bool
+	value  func(el *eventLog, req pkg.Stream) string
+	// less orders two requests by this column. If nil, the rendered values
+	// are compared as strings.
+	less func(a, b pkg.Stream) bool
+	// color, if set, returns the text color of a request's cell.
+	color func(el *eventLog, req pkg.Stream) tcell.Color
+	// optional columns are only shown when chosen with --columns.
+	optional bool
+}
+
+var columns = []column{
+	{
+		header: "TIME",
+		value:  timestamp,
+		less: func(a, b pkg.Stream) bool {
+			if a.TimestampMs == b.TimestampMs {
+				return a.Seq < b.Seq
+			}
+			return a.TimestampMs < b.TimestampMs
+		},
+	},
+	{
+		header:   "SEQ",
+		padded:   true,
+		optional: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return fmt.Sprintf("%d", req.Seq)
+		},
+		less: func(a, b pkg.Stream) bool {
+			return a.Seq < b.Seq
+		},
+	},
+	{
+		header: "FROM",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			from, _, _ := el.fromPodTo(req)
+			return from
+		},
+	},
+	{
+		header: "POD",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			_, pod, _ := el.fromPodTo(req)
+			return pod
+		},
+	},
+	{
+		header: "TO",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			_, _, to := el.fromPodTo(req)
+			return to
+		},
+	},
+	{
+		header: "TO-SVC",
+		padded: true,
+		value:  toService,
+	},
+	{
+		header:   "ZONE",
+		padded:   true,
+		optional: true,
+		value:    zoneText,
+	},
+	{
+		header:   "SCHEME",
+		padded:   true,
+		optional: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return scheme(req)
+		},
+	},
+	{
+		header: "VERB",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return req.ReqInit.GetMethod().GetRegistered().String()
+		},
+		color: verbColor,
+	},
+	{
+		header: "PATH",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return req.ReqInit.GetPath()
+		},
+	},
+	{
+		header: "STATUS",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return status(req)
+		},
+		less: func(a, b pkg.Stream) bool {
+			return a.RspInit.GetHttpStatus() < b.RspInit.GetHttpStatus()
+		},
+	},
+	{
+		header: "CLASS",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			class, _ := classify(req)
+			return class
+		},
+		color: func(el *eventLog, req pkg.Stream) tcell.Color {
+			if isSuccess(req) {
+				return el.theme.success
+			}
+			return el.theme.serverError
+		},
+	},
+	{
+		header: "LATENCY",
+		value: func(el *eventLog, req pkg.Stream) string {
+			return el.formatLatency(req)
+		},
+		color: latencyColor,
+		less: func(a, b pkg.Stream) bool {
+			return latencyDuration(a) < latencyDuration(b)
+		},
+	},
+	{
+		header:   latencyBarHeader,
+		padded:   true,
+		optional: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return latencyBar(latencyDuration(req), el.maxLatency)
+		},
+		less: func(a, b pkg.Stream) bool {
+			return latencyDuration(a) < latencyDuration(b)
+		},
+	},
+	{
+		header: "REQ-HDRS",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return fmt.Sprintf("%d", requestHeaderCount(req))
+		},
+		less: func(a, b pkg.Stream) bool {
+			return requestHeaderCount(a) < requestHeaderCount(b)
+		},
+	},
+	{
+		header: "RSP-HDRS",
+		padded: true,
+		value: func(el *eventLog, req pkg.Stream) string {
+			return fmt.Sprintf("%d", responseHeaderCount(req))
+		},
+		less: func(a, b pkg.Stream) bool {
+			return responseHeaderCount(a) < responseHeaderCount(b)
+		},
+	},
+}
+
+// selectColumns returns the columns with the given headers, in the order
+// given, or the default columns if none are given. Headers are matched without
+// regard to case.
+func selectColumns(headers []string) ([]column, error) {
+	if len(headers) == 0 {
+		var selected []column
+		for _, col := range columns {
+			if !col.optional {
+				selected = append(selected, col)
+			}
+		}
+		return selected, nil
+	}
+
+	var selected []column
+	for _, header := range headers {
+		found := false
+		for _, col := range columns {
+			if strings.EqualFold(col.header, header) {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q; valid columns are %s", header, strings.Join(columnHeaders(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+func columnHeaders() []string {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.header
+	}
+	return headers
+}
+
+func (c column) cell(el *eventLog, req pkg.Stream) string {
+	if c.padded {
+		return pad(c.value(el, req))
+	}
+	return c.value(el, req)
+}
+
+func (c column) compare(el *eventLog, a, b pkg.Stream) bool {
+	if c.less != nil {
+		return c.less(a, b)
+	}
+	return c.value(el, a) < c.value(el, b)
+}
+
+func timestamp(el *eventLog, req pkg.Stream) string {
+	return el.formatTime(req.TimestampMs, req.Time)
+}
+
+// formatTime renders a time as seconds since the capture started, or as the
+// wall clock time t if absolute times are shown and t is known.
+func (el *eventLog) formatTime(ms uint64, t time.Time) string {
+	if el.absoluteTime && !t.IsZero() {
+		return t.In(el.location).Format("15:04:05.000")
+	}
+	return formatTimestamp(ms)
+}
+
+// formatTimestamp renders milliseconds since the capture started as seconds.
+func formatTimestamp(ms uint64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000.0)
+}
+
+// toService returns the service the client addressed, which may differ from
+// the pod that served the request.
+func toService(el *eventLog, req pkg.Stream) string {
+	return req.Event.GetDestinationMeta().GetLabels()["service"]
+}
+
+// status renders the HTTP status of a response. Streams that ended before
+// any response headers were received have no status; those that were reset
+// are shown with the reset reason.
+func status(req pkg.Stream) string {
+	if req.RspInit == nil {
+		if reset, ok := req.RspEnd.GetEos().GetEnd().(*metricsPb.Eos_ResetErrorCode); ok {
+			return fmt.Sprintf("RST %s", resetReason(reset.ResetErrorCode))
+		}
+		return "-"
+	}
+	return fmt.Sprintf("%d", req.RspInit.GetHttpStatus())
+}
+
+func requestHeaderCount(req pkg.Stream) int {
+	return len(req.ReqInit.GetHeaders().GetHeaders())
+}
+
+func responseHeaderCount(req pkg.Stream) int {
+	return len(req.RspInit.GetHeaders().GetHeaders())
+}
+
+const (
+	latencyBarHeader = "LATENCY-BAR"
+	latencyBarWidth  = 10
+)
+
+// latencyBarGlyphs are the partial blocks used to draw the end of a latency
+// bar, in eighths of a cell.
+var latencyBarGlyphs = []rune(" ▏▎▍▌▋▊▉")
+
+// latencyBar draws latency as a bar whose full width is max.
+func latencyBar(latency, max time.Duration) string {
+	if max <= 0 {
+		return strings.Repeat(" ", latencyBarWidth)
+	}
+	// A negative latency, which a proxy shouldn't report but may, draws
+	// no bar, and one over max a full one.
+	if latency < 0 {
+		latency = 0
+	} else if latency > max {
+		latency = max
+	}
+	eighths := int(int64(latency) * latencyBarWidth * 8 / int64(max))
+	bar := strings.Repeat("█", eighths/8)
+	if eighths%8 > 0 {
+		bar += string(latencyBarGlyphs[eighths%8])
+	}
+	return bar + strings.Repeat(" ", latencyBarWidth-utf8.RuneCountInString(bar))
+}
+
+func (el *eventLog) showsColumn(header string) bool {
+	for _, col := range el.columns {
+		if col.header == header {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleLatencyBar shows or hides the latency bar column, just after LATENCY
+// if that column is shown.
+func (el *eventLog) toggleLatencyBar() {
+	var sortHeader string
+	if el.sortColumn >= 0 {
+		sortHeader = el.columns[el.sortColumn].header
+	}
+
+	shown := false
+	for i, col := range el.columns {
+		if col.header == latencyBarHeader {
+			el.columns = append(el.columns[:i:i], el.columns[i+1:]...)
+			shown = true
+			break
+		}
+	}
+	if !shown {
+		bar, _ := selectColumns([]string{latencyBarHeader})
+		at := len(el.columns)
+		for i, col := range el.columns {
+			if col.header == "LATENCY" {
+				at = i + 1
+			}
+		}
+		el.columns = append(el.columns[:at:at], append(bar, el.columns[at:]...)...)
+	}
+
+	el.sortColumn = -1
+	for i, col := range el.columns {
+		if sortHeader != "" && col.header == sortHeader {
+			el.sortColumn = i
+		}
+	}
+	el.table.Clear()
+	el.outbound.Clear()
+	el.render()
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The Linkerd releases whose tap API tapshark is known to decode, as (major,
+// minor) for stable releases and (year, month) for edge releases. The newest
+// are those closest to the linkerd2 module tapshark is built from.
+var (
+	oldestStableRelease = [2]int{2, 10}
+	newestStableRelease = [2]int{2, 12}
+	oldestEdgeRelease   = [2]int{21, 1}
+	newestEdgeRelease   = [2]int{22, 8}
+)
+
+// tapServerVersion returns the Linkerd release that installed the tap server,
+// such as stable-2.12.0, from the annotation on its deployment.
+func tapServerVersion(ctx context.Context, k8sAPI *k8s.KubernetesAPI) (string, error) {
+	ns, err := k8sAPI.GetNamespaceWithExtensionLabel(ctx, "viz")
+	if err != nil {
+		return "", err
+	}
+	deploy, err := k8sAPI.AppsV1().Deployments(ns.Name).Get(ctx, "tap", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	// The annotation is the installer and the release, such as
+	// "linkerd/helm stable-2.12.0".
+	fields := strings.Fields(deploy.Annotations[k8s.CreatedByAnnotation])
+	if len(fields) == 0 {
+		return "", fmt.Errorf("the tap deployment has no %s annotation", k8s.CreatedByAnnotation)
+	}
+	return fields[len(fields)-1], nil
+}
+
+// tapCompatibility returns a warning if version is a release whose tap API
+// may differ from the one tapshark was built against, so that blank fields
+// and missing requests aren't mistaken for what the proxy reported. Versions
+// it can't parse, such as development builds, aren't warned about.
+func tapCompatibility(version string) string {
+	channel, rest, ok := cutString(version, "-")
+	if !ok {
+		return ""
+	}
+	parts := strings.SplitN(rest, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	release := [2]int{major, minor}
+
+	var oldest, newest [2]int
+	switch channel {
+	case "stable":
+		oldest, newest = oldestStableRelease, newestStableRelease
+	case "edge":
+		oldest, newest = oldestEdgeRelease, newestEdgeRelease
+	default:
+		return ""
+	}
+	switch {
+	case releaseBefore(release, oldest):
+		return fmt.Sprintf("the tap server is Linkerd %s, older than tapshark supports; some fields may be blank", version)
+	case releaseBefore(newest, release):
+		return fmt.Sprintf("the tap server is Linkerd %s, newer than tapshark was built for; new fields and event types aren't shown", version)
+	}
+	return ""
+}
+
+func releaseBefore(a, b [2]int) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
+
+// cutString splits s around the first sep, like strings.Cut, which needs a
+// newer Go than this module targets.
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/rivo/tview"
+)
+
+// A connection is identified by the addresses of both of its ends. The
+// client's address includes its ephemeral port, so each connection a client
+// opens is counted separately.
+type connection struct {
+	source, destination string
+}
+
+type connectionStats struct {
+	requests        int
+	firstMs, lastMs uint64
+}
+
+// renderConnections shows how many requests each connection carried, busiest
+// first. A few connections with many requests each means they are being
+// reused; many connections with one request each means they aren't.
+func (el *eventLog) renderConnections(table *tview.Table, events []pkg.Stream) {
+	stats := make(map[connection]*connectionStats)
+	for _, req := range events {
+		conn := connection{
+			source:      el.anonymizer.address(req.Event.GetSource()),
+			destination: el.anonymizer.address(req.Event.GetDestination()),
+		}
+		s, ok := stats[conn]
+		if !ok {
+			s = &connectionStats{firstMs: req.TimestampMs}
+			stats[conn] = s
+		}
+		s.requests++
+		if req.TimestampMs < s.firstMs {
+			s.firstMs = req.TimestampMs
+		}
+		if req.TimestampMs > s.lastMs {
+			s.lastMs = req.TimestampMs
+		}
+	}
+	conns := make([]connection, 0, len(stats))
+	for conn := range stats {
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if stats[conns[i]].requests != stats[conns[j]].requests {
+			return stats[conns[i]].requests > stats[conns[j]].requests
+		}
+		if conns[i].source != conns[j].source {
+			return conns[i].source < conns[j].source
+		}
+		return conns[i].destination < conns[j].destination
+	})
+
+	setHeader(table, "SOURCE", pad("DESTINATION"), pad("REQUESTS"), pad("FIRST"), "LAST")
+	for i, conn := range conns {
+		s := stats[conn]
+		table.SetCellSimple(i+1, 0, conn.source)
+		table.SetCellSimple(i+1, 1, pad(conn.destination))
+		table.SetCellSimple(i+1, 2, pad(fmt.Sprintf("%d", s.requests)))
+		table.SetCellSimple(i+1, 3, pad(formatTimestamp(s.firstMs)))
+		table.SetCellSimple(i+1, 4, formatTimestamp(s.lastMs))
+	}
+	truncateRows(table, len(conns)+1)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+	netPb "github.com/linkerd/linkerd2/controller/gen/common/net"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// newTestEventLog returns an eventLog with the default columns whose UI runs
+// on a simulation screen until the test ends.
+func newTestEventLog(t *testing.T) *eventLog {
+	t.Helper()
+	columns, err := selectColumns(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := selectDetailFields(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme, err := lookupTheme(defaultTheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el := newEventLog(&options{location: time.UTC, sampleRate: 1}, nil, nil, theme, columns, fields, nil)
+
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	el.app.SetScreen(screen)
+	stopped := make(chan struct{})
+	go func() {
+		el.app.Run()
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		el.quit()
+		<-stopped
+	})
+	return el
+}
+
+// tapEvent returns an HTTP tap event between two fixed pods.
+func tapEvent(http *tapPb.TapEvent_Http) *tapPb.TapEvent {
+	return &tapPb.TapEvent{
+		Source:          &netPb.TcpAddress{Ip: &netPb.IPAddress{Ip: &netPb.IPAddress_Ipv4{Ipv4: 0x0a000001}}, Port: 1234},
+		SourceMeta:      &tapPb.TapEvent_EndpointMeta{Labels: map[string]string{"pod": "client"}},
+		Destination:     &netPb.TcpAddress{Ip: &netPb.IPAddress{Ip: &netPb.IPAddress_Ipv4{Ipv4: 0x0a000002}}, Port: 80},
+		DestinationMeta: &tapPb.TapEvent_EndpointMeta{Labels: map[string]string{"pod": "server"}},
+		ProxyDirection:  tapPb.TapEvent_INBOUND,
+		Event:           &tapPb.TapEvent_Http_{Http: http},
+	}
+}
+
+func tapRequestInit(stream uint64) *tapPb.TapEvent {
+	return tapEvent(&tapPb.TapEvent_Http{Event: &tapPb.TapEvent_Http_RequestInit_{
+		RequestInit: &tapPb.TapEvent_Http_RequestInit{Id: &tapPb.TapEvent_Http_StreamId{Base: 1, Stream: stream}},
+	}})
+}
+
+func tapResponseEnd(stream uint64) *tapPb.TapEvent {
+	return tapEvent(&tapPb.TapEvent_Http{Event: &tapPb.TapEvent_Http_ResponseEnd_{
+		ResponseEnd: &tapPb.TapEvent_Http_ResponseEnd{Id: &tapPb.TapEvent_Http_StreamId{Base: 1, Stream: stream}},
+	}})
+}
+
+// TestConsumeTapReusedStreamID checks that when a stream ID is reused before
+// its request ends, both the incomplete request and the one that reused the
+// ID are shown, rather than one being dropped as a duplicate of the other.
+func TestConsumeTapReusedStreamID(t *testing.T) {
+	el := newTestEventLog(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventCh := make(chan *tapPb.TapEvent)
+	requestCh := make(chan pkg.Stream, 100)
+	inProgress := make(chan pkg.Stream, 100)
+	go pkg.ProcessEvents(ctx, eventCh, pkg.ProgressChanSink{Done: requestCh, InProgress: inProgress, Stop: ctx.Done()})
+	el.goUpdating(func() { el.consumeTap(ctx, requestCh, nil, inProgress, el.done) })
+
+	for _, event := range []*tapPb.TapEvent{tapRequestInit(1), tapRequestInit(1), tapResponseEnd(1)} {
+		eventCh <- event
+	}
+
+	var ended []bool
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ended) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		el.app.QueueUpdate(func() {
+			ended = ended[:0]
+			for _, req := range el.events {
+				ended = append(ended, req.RspEnd != nil)
+			}
+		})
+	}
+	if len(ended) != 2 || ended[0] || !ended[1] {
+		t.Fatalf("expected an incomplete and then a complete request, got %v", ended)
+	}
+}
+
+// TestPauseKeepsCounting checks that requests completed while the view is
+// paused are kept in the event list but not shown until it resumes.
+func TestPauseKeepsCounting(t *testing.T) {
+	el := newTestEventLog(t)
+	el.app.QueueUpdate(el.togglePause)
+
+	for i := uint64(1); i <= 3; i++ {
+		event := tapRequestInit(i)
+		el.showRequest(pkg.Stream{Event: event, ReqInit: event.GetHttp().GetRequestInit(), RspEnd: tapResponseEnd(i).GetHttp().GetResponseEnd()})
+	}
+	var events, rows int
+	el.app.QueueUpdate(func() { events, rows = len(el.events), len(el.rows) })
+	if events != 3 || rows != 0 {
+		t.Fatalf("expected 3 requests and no rows while paused, got %d and %d", events, rows)
+	}
+
+	el.app.QueueUpdate(el.togglePause)
+	el.app.QueueUpdate(func() { events, rows = len(el.events), len(el.rows) })
+	if events != 3 || rows != 3 {
+		t.Fatalf("expected 3 requests and 3 rows after resuming, got %d and %d", events, rows)
+	}
+}
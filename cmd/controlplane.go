@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/healthcheck"
+	vizHealthCheck "github.com/linkerd/linkerd2/viz/pkg/healthcheck"
+	log "github.com/sirupsen/logrus"
+)
+
+// The wait between health checks with --wait-for-control-plane starts at
+// minControlPlaneBackoff and doubles after each failure, up to
+// maxControlPlaneBackoff.
+const (
+	minControlPlaneBackoff = time.Second
+	maxControlPlaneBackoff = 30 * time.Second
+)
+
+// waitForControlPlane runs the health checks that api.CheckClientOrExit does
+// until they pass, waiting longer after each failure. It gives up with the
+// last failure once timeout has passed or ctx is done.
+func waitForControlPlane(ctx context.Context, hcOptions healthcheck.Options, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := minControlPlaneBackoff
+	for {
+		err := checkControlPlane(hcOptions)
+		if err == nil {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("the control plane wasn't ready within %s: %w", timeout, err)
+		}
+		wait := backoff
+		if wait > remaining {
+			wait = remaining
+		}
+		log.Infof("Waiting %s for the control plane: %v", wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > maxControlPlaneBackoff {
+			backoff = maxControlPlaneBackoff
+		}
+	}
+}
+
+// checkControlPlane runs the health checks once and returns the first that
+// failed.
+func checkControlPlane(hcOptions healthcheck.Options) error {
+	hcOptions.RetryDeadline = time.Time{}
+	hc := vizHealthCheck.NewHealthChecker([]healthcheck.CategoryID{healthcheck.KubernetesAPIChecks}, &hcOptions)
+	hc.AppendCategories(hc.VizCategory())
+	var failure error
+	hc.RunChecks(func(result *healthcheck.CheckResult) {
+		if result.Err != nil && !result.Warning && failure == nil {
+			failure = fmt.Errorf("%s: %w", result.Description, result.Err)
+		}
+	})
+	return failure
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// csvHeader is the first row of --output csv: the default columns of the
+// table, then the identity of each peer.
+var csvHeader = []string{"TIME", "FROM", "POD", "TO", "VERB", "PATH", "STATUS", "LATENCY", "SOURCE-IDENTITY", "DESTINATION-IDENTITY"}
+
+// A csvEncoder writes a row per request with the values the table shows for
+// it, so that a capture can be grepped or opened in a spreadsheet. The
+// capture's metadata isn't written, and the rows can't be read back with
+// --from-json-file.
+type csvEncoder struct {
+	writer *csv.Writer
+	// el formats the peers as the table does, anonymizing them with
+	// --anonymize. It has no UI.
+	el *eventLog
+}
+
+func newCSVEncoder(w io.Writer, options *options) *csvEncoder {
+	el := &eventLog{fullAddress: options.fullAddress, latencyUnit: options.latencyUnit}
+	if options.anonymize {
+		el.anonymizer = newAnonymizer()
+	}
+	return &csvEncoder{writer: csv.NewWriter(w), el: el}
+}
+
+// Encode writes the header row for a captureRecord. Requests are written by
+// encodeStream instead.
+func (e *csvEncoder) Encode(record interface{}) error {
+	if _, ok := record.(captureRecord); !ok {
+		return nil
+	}
+	e.writer.Write(csvHeader)
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// encodeStream implements streamEncoder. Every row is flushed as it is
+// written, so that a capture can be followed with tail -f.
+func (e *csvEncoder) encodeStream(req pkg.Stream) error {
+	e.writer.Write(e.row(req))
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) row(req pkg.Stream) []string {
+	from, pod, to := e.el.fromPodTo(req)
+	return []string{
+		formatTimestamp(req.TimestampMs),
+		from,
+		pod,
+		to,
+		req.ReqInit.GetMethod().GetRegistered().String(),
+		req.ReqInit.GetPath(),
+		status(req),
+		e.el.formatLatency(req),
+		identity(req.Event.GetSourceMeta().GetLabels()),
+		identity(req.Event.GetDestinationMeta().GetLabels()),
+	}
+}
+
+// identity names the service account a peer runs as, from which Linkerd
+// derives its mTLS identity, as serviceaccount.namespace. It is empty for
+// peers that aren't pods.
+func identity(labels map[string]string) string {
+	if labels["serviceaccount"] == "" {
+		return ""
+	}
+	return labels["serviceaccount"] + "." + labels["namespace"]
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+)
+
+// deadlineWarning is the fraction of its gRPC deadline past which a request
+// is shown as at risk of exceeding it.
+const deadlineWarning = 0.8
+
+// grpcTimeoutUnits are the units a grpc-timeout header may be given in.
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGrpcTimeout parses the value of a grpc-timeout header, which is at
+// most eight digits followed by a unit, such as 100m for 100 milliseconds. A
+// timeout too long for a time.Duration, such as 99999999H, is capped.
+func parseGrpcTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	if n > uint64(math.MaxInt64/unit) {
+		return math.MaxInt64, true
+	}
+	return time.Duration(n) * unit, true
+}
+
+// grpcDeadline returns the deadline the client gave req, if it sent one. A
+// timeout of 0 is taken as no deadline, since no request could meet it.
+func grpcDeadline(req pkg.Stream) (time.Duration, bool) {
+	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+		if strings.EqualFold(header.GetName(), "grpc-timeout") {
+			deadline, ok := parseGrpcTimeout(header.GetValueStr())
+			return deadline, ok && deadline > 0
+		}
+	}
+	return 0, false
+}
+
+// deadlineUsed returns the fraction of its gRPC deadline that req took.
+func deadlineUsed(req pkg.Stream) (float64, bool) {
+	deadline, ok := grpcDeadline(req)
+	if !ok {
+		return 0, false
+	}
+	return float64(latencyDuration(req)) / float64(deadline), true
+}
+
+// latencyColor flags requests that came close to or exceeded their gRPC
+// deadline. Other requests are shown in the color of their row.
+func latencyColor(el *eventLog, req pkg.Stream) tcell.Color {
+	used, ok := deadlineUsed(req)
+	switch {
+	case !ok:
+		return el.rowColor(req)
+	case used >= 1:
+		return el.theme.serverError
+	case used >= deadlineWarning:
+		return el.theme.clientError
+	default:
+		return el.rowColor(req)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// recentCapacity is the number of request ids remembered for deduplication.
+const recentCapacity = 10000
+
+// recentIDs remembers the most recently seen requests so that a request
+// reported more than once, such as by overlapping tap streams, is only shown
+// once. It is safe for concurrent use.
+type recentIDs struct {
+	sync.Mutex
+	seen  map[recentKey]struct{}
+	order []recentKey
+	next  int
+}
+
+// A recentKey identifies a request for deduplication. A request that was
+// emitted without an end, because its stream ID was reused, has the same
+// StreamID as the request that reused it, so whether it completed tells the
+// two apart.
+type recentKey struct {
+	id       pkg.StreamID
+	complete bool
+}
+
+func newRecentIDs() *recentIDs {
+	return &recentIDs{
+		seen:  make(map[recentKey]struct{}, recentCapacity),
+		order: make([]recentKey, 0, recentCapacity),
+	}
+}
+
+// add records req and reports whether it had not been seen recently.
+func (r *recentIDs) add(req pkg.Stream) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	key := recentKey{id: req.ID(), complete: req.RspEnd != nil}
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+	if len(r.order) < recentCapacity {
+		r.order = append(r.order, key)
+	} else {
+		delete(r.seen, r.order[r.next])
+		r.order[r.next] = key
+		r.next = (r.next + 1) % recentCapacity
+	}
+	r.seen[key] = struct{}{}
+	return true
+}
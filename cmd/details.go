@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/golang/protobuf/ptypes"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+const fieldTemplate = "[::b]%s:[-:-:-] %s\n"
+
+// A detailField is one entry in the details pane.
+type detailField struct {
+	name string
+	// response fields are shown in the response half of the pane.
+	response bool
+	// section groups related fields; a blank line separates one section
+	// from the next.
+	section string
+	// optional fields are only shown when chosen with --detail-fields.
+	optional bool
+	// write writes the field for req to w and returns whether it wrote
+	// anything.
+	write func(el *eventLog, w detailWriter, req pkg.Stream) bool
+}
+
+var detailFields = []detailField{
+	{
+		name:    "pod",
+		section: "peers",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			_, pod, _ := el.fromPodTo(req)
+			return writeField(w, "Pod", pod)
+		},
+	},
+	{
+		name:    "from",
+		section: "peers",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			from, _, _ := el.fromPodTo(req)
+			return from != "" && writeField(w, "From", from)
+		},
+	},
+	{
+		name:    "to",
+		section: "peers",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			_, _, to := el.fromPodTo(req)
+			return to != "" && writeField(w, "To", to)
+		},
+	},
+	{
+		name:    "source",
+		section: "endpoints",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Source", el.anonymizer.address(req.Event.GetSource()))
+		},
+	},
+	{
+		name:    "source-metadata",
+		section: "endpoints",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return el.writeMetadata(w, "Source Metadata", req.Event.GetSourceMeta())
+		},
+	},
+	{
+		name:    "destination",
+		section: "endpoints",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Destination", el.anonymizer.address(req.Event.GetDestination()))
+		},
+	},
+	{
+		name:    "destination-metadata",
+		section: "endpoints",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return el.writeMetadata(w, "Destination Metadata", req.Event.GetDestinationMeta())
+		},
+	},
+	{
+		name:    "tls",
+		section: "identity",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "TLS", describeTLS(req))
+		},
+	},
+	{
+		name:     "tls-version",
+		section:  "identity",
+		optional: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "TLS Version", tlsDetail(req, tlsVersionLabels))
+		},
+	},
+	{
+		name:     "tls-cipher",
+		section:  "identity",
+		optional: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "TLS Cipher", tlsDetail(req, tlsCipherLabels))
+		},
+	},
+	{
+		name:    "route-metadata",
+		section: "route",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			labels := req.Event.GetRouteMeta().GetLabels()
+			if len(labels) == 0 {
+				return false
+			}
+			var pairs []detailPair
+			for k, v := range labels {
+				pairs = append(pairs, detailPair{k, v})
+			}
+			return writeList(w, "Route Metadata", pairs)
+		},
+	},
+	{
+		name:    "scheme",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Scheme", scheme(req))
+		},
+	},
+	{
+		name:    "verb",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			value := req.ReqInit.GetMethod().GetRegistered().String()
+			if warning, ok := bodyWarning(req); ok {
+				value += fmt.Sprintf(" (%s %s)", bodyWarningMarker, warning)
+			}
+			return writeField(w, "Verb", value)
+		},
+	},
+	{
+		name:    "path",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Path", req.ReqInit.GetPath())
+		},
+	},
+	{
+		name:    "authority",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Authority", el.anonymizer.authority(req.ReqInit.GetAuthority()))
+		},
+	},
+	{
+		name:    "host",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			host, _ := splitAuthority(el.anonymizer.authority(req.ReqInit.GetAuthority()))
+			return writeField(w, "Host", host)
+		},
+	},
+	{
+		name:    "port",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			_, port := splitAuthority(req.ReqInit.GetAuthority())
+			if port == "" {
+				port = defaultPort(scheme(req))
+			}
+			return writeField(w, "Port", port)
+		},
+	},
+	{
+		name:    "request-headers",
+		section: "request",
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeHeaders(w, "Request Headers", req.ReqInit.GetHeaders())
+		},
+	},
+	{
+		name:     "latency",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Latency", el.formatLatency(req))
+		},
+	},
+	{
+		name:     "deadline",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			deadline, ok := grpcDeadline(req)
+			if !ok {
+				return false
+			}
+			used, _ := deadlineUsed(req)
+			return writeField(w, "gRPC Deadline", fmt.Sprintf("%s (%.0f%% used)", deadline, 100*used))
+		},
+	},
+	{
+		name:     "time-to-headers",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			wait, ok := timeToHeaders(req)
+			if !ok {
+				return false
+			}
+			value := el.formatDuration(wait)
+			if size := requestBodySize(req); size > 0 {
+				value += fmt.Sprintf(" (includes uploading the %d byte request body)", size)
+			}
+			return writeField(w, "Time to Headers", value)
+		},
+	},
+	{
+		name:     "status",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "Status", status(req))
+		},
+	},
+	{
+		name:     "duration",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			var duration string
+			d, err := ptypes.Duration(req.RspEnd.GetSinceResponseInit())
+			if err == nil {
+				duration = el.formatDuration(d)
+			}
+			return writeField(w, "Duration", duration)
+		},
+	},
+	{
+		name:     "end-of-stream",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeField(w, "End of Stream", endOfStream(req))
+		},
+	},
+	{
+		name:     "classification",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			class, reason := classify(req)
+			if reason != "" {
+				class = fmt.Sprintf("%s (%s)", class, reason)
+			}
+			return writeField(w, "Classification", class)
+		},
+	},
+	{
+		name:     "response-headers",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeHeaders(w, "Response Headers", req.RspInit.GetHeaders())
+		},
+	},
+	{
+		name:     "response-trailers",
+		response: true,
+		write: func(el *eventLog, w detailWriter, req pkg.Stream) bool {
+			return writeHeaders(w, "Response Trailers", req.RspEnd.GetTrailers())
+		},
+	},
+}
+
+// selectDetailFields returns the detail fields with the given names, in the
+// order given, or the default fields if none are given. Names are matched
+// without regard to case.
+func selectDetailFields(names []string) ([]detailField, error) {
+	if len(names) == 0 {
+		var selected []detailField
+		for _, field := range detailFields {
+			if !field.optional {
+				selected = append(selected, field)
+			}
+		}
+		return selected, nil
+	}
+
+	var selected []detailField
+	for _, name := range names {
+		found := false
+		for _, field := range detailFields {
+			if strings.EqualFold(field.name, name) {
+				selected = append(selected, field)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detail field %q; valid fields are %s", name, strings.Join(detailFieldNames(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+func detailFieldNames() []string {
+	names := make([]string, len(detailFields))
+	for i, field := range detailFields {
+		names[i] = field.name
+	}
+	return names
+}
+
+// writeDetails writes the chosen detail fields for req, in the given format,
+// to the request and response halves of the details.
+func (el *eventLog) writeDetails(req pkg.Stream, request, response io.Writer, format func(io.Writer) detailWriter) {
+	// lastSection holds the section of the last field written to each
+	// half.
+	lastSection := map[io.Writer]string{}
+	for _, field := range el.detailFields {
+		w := request
+		if field.response {
+			w = response
+		}
+		// Fields are written to a buffer first so that the separating
+		// blank line is only added for fields that have something to show.
+		var buf strings.Builder
+		if !field.write(el, format(&buf), req) {
+			continue
+		}
+		if last, ok := lastSection[w]; ok && last != field.section {
+			fmt.Fprintln(w)
+		}
+		lastSection[w] = field.section
+		io.WriteString(w, buf.String())
+	}
+}
+
+type (
+	// A detailWriter formats detail fields, such as for the details pane or
+	// as Markdown.
+	detailWriter interface {
+		field(name, value string)
+		// list writes a field made up of a list of pairs, such as headers.
+		list(name string, pairs []detailPair)
+	}
+
+	detailPair struct {
+		key, value string
+	}
+
+	// textDetails formats fields for a tview.TextView.
+	textDetails struct {
+		io.Writer
+	}
+)
+
+func newTextDetails(w io.Writer) detailWriter {
+	return textDetails{w}
+}
+
+func (w textDetails) field(name, value string) {
+	fmt.Fprintf(w, fieldTemplate, name, value)
+}
+
+func (w textDetails) list(name string, pairs []detailPair) {
+	w.field(name, "")
+	for _, pair := range pairs {
+		fmt.Fprintf(w, "\t%s: %s\n", pair.key, pair.value)
+	}
+}
+
+// timeToHeaders returns the time from the start of the request until the
+// response headers arrived. The tap doesn't report when the request body
+// finished, so for requests with a body this is the time spent uploading it
+// and the time spent waiting for the server together. It is derived from the
+// response end, which every completed request has, rather than the response
+// headers, so that it is also known for replayed captures.
+func timeToHeaders(req pkg.Stream) (time.Duration, bool) {
+	if req.RspInit == nil {
+		return 0, false
+	}
+	latency, err := ptypes.Duration(req.RspEnd.GetSinceRequestInit())
+	if err != nil {
+		return 0, false
+	}
+	duration, err := ptypes.Duration(req.RspEnd.GetSinceResponseInit())
+	if err != nil {
+		return 0, false
+	}
+	return latency - duration, true
+}
+
+// requestBodySize returns the content-length of the request, or 0 if it has
+// none, such as a streaming request.
+func requestBodySize(req pkg.Stream) int64 {
+	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+		if strings.EqualFold(header.GetName(), "content-length") {
+			size, _ := strconv.ParseInt(header.GetValueStr(), 10, 64)
+			return size
+		}
+	}
+	return 0
+}
+
+func writeField(w detailWriter, name, value string) bool {
+	w.field(name, value)
+	return true
+}
+
+func writeList(w detailWriter, name string, pairs []detailPair) bool {
+	w.list(name, pairs)
+	return true
+}
+
+func (el *eventLog) writeMetadata(w detailWriter, name string, meta *tapPb.TapEvent_EndpointMeta) bool {
+	var pairs []detailPair
+	for k, v := range meta.GetLabels() {
+		pairs = append(pairs, detailPair{k, el.anonymizer.label(k, v)})
+	}
+	return writeList(w, name, pairs)
+}
+
+func writeHeaders(w detailWriter, name string, headers *metricsPb.Headers) bool {
+	var pairs []detailPair
+	for _, header := range headers.GetHeaders() {
+		pairs = append(pairs, detailPair{header.GetName(), header.GetValueStr()})
+	}
+	return writeList(w, name, pairs)
+}
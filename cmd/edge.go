@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adleong/tapshark/pkg"
+	netPb "github.com/linkerd/linkerd2/controller/gen/common/net"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// edgePeer names one end of req's edge: its workload or pod if it has one,
+// or else its IP address. Ports are left out so that every connection between
+// the same peers is on the same edge.
+func (el *eventLog) edgePeer(address *netPb.TcpAddress, meta *tapPb.TapEvent_EndpointMeta) string {
+	if workload := el.transientWorkload(meta); workload != "" {
+		return workload
+	}
+	if pod := meta.GetLabels()["pod"]; pod != "" {
+		return el.anonymizer.pod(pod)
+	}
+	return stripPort(el.anonymizer.address(address))
+}
+
+// onEdge reports whether req went from source to destination.
+func (el *eventLog) onEdge(req pkg.Stream, source, destination string) bool {
+	return el.edgePeer(req.Event.GetSource(), req.Event.GetSourceMeta()) == source &&
+		el.edgePeer(req.Event.GetDestination(), req.Event.GetDestinationMeta()) == destination
+}
+
+// toggleEdgeFilter shows only the requests between the same client and server
+// as the request in the details pane, or shows every request again if only
+// one edge is already shown. Hidden requests are still counted in the
+// summary views.
+func (el *eventLog) toggleEdgeFilter() {
+	if el.edgeFilter != nil {
+		el.edgeFilter = nil
+		el.edgeName = ""
+	} else {
+		if el.detailEvent < 0 {
+			return
+		}
+		req := el.events[el.detailEvent]
+		source := el.edgePeer(req.Event.GetSource(), req.Event.GetSourceMeta())
+		destination := el.edgePeer(req.Event.GetDestination(), req.Event.GetDestinationMeta())
+		el.edgeFilter = func(req pkg.Stream) bool {
+			return el.onEdge(req, source, destination)
+		}
+		el.edgeName = fmt.Sprintf("%s → %s", source, destination)
+	}
+	selected := el.detailEvent
+	el.render()
+	if selected >= 0 {
+		el.selectEvent(selected)
+	}
+	el.updateStatus()
+}
+
+// shown reports whether req passes the edge filter, if there is one.
+func (el *eventLog) shown(req pkg.Stream) bool {
+	return el.edgeFilter == nil || el.edgeFilter(req)
+}
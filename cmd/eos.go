@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adleong/tapshark/pkg"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+)
+
+// http2ErrorCodes names the HTTP/2 error codes that a stream can be reset
+// with, from RFC 7540 section 7.
+var http2ErrorCodes = []string{
+	"NO_ERROR",
+	"PROTOCOL_ERROR",
+	"INTERNAL_ERROR",
+	"FLOW_CONTROL_ERROR",
+	"SETTINGS_TIMEOUT",
+	"STREAM_CLOSED",
+	"FRAME_SIZE_ERROR",
+	"REFUSED_STREAM",
+	"CANCEL",
+	"COMPRESSION_ERROR",
+	"CONNECT_ERROR",
+	"ENHANCE_YOUR_CALM",
+	"INADEQUATE_SECURITY",
+	"HTTP_1_1_REQUIRED",
+}
+
+// grpcStatusCodes names the gRPC status codes.
+var grpcStatusCodes = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+func resetReason(code uint32) string {
+	return codeName(http2ErrorCodes, code)
+}
+
+func grpcStatusName(code uint32) string {
+	return codeName(grpcStatusCodes, code)
+}
+
+func codeName(names []string, code uint32) string {
+	if int(code) < len(names) {
+		return names[code]
+	}
+	return fmt.Sprintf("%d", code)
+}
+
+// endOfStream describes how a response stream ended: cleanly, with a gRPC
+// status, by being reset, or not at all, if its stream ID was reused first.
+func endOfStream(req pkg.Stream) string {
+	if req.RspEnd == nil {
+		return "incomplete (the proxy reused its stream ID before it ended)"
+	}
+	switch eos := req.RspEnd.GetEos().GetEnd().(type) {
+	case *metricsPb.Eos_GrpcStatusCode:
+		return fmt.Sprintf("gRPC status %d (%s)", eos.GrpcStatusCode, grpcStatusName(eos.GrpcStatusCode))
+	case *metricsPb.Eos_ResetErrorCode:
+		return fmt.Sprintf("reset with error code %d (%s)", eos.ResetErrorCode, resetReason(eos.ResetErrorCode))
+	}
+	return "complete"
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
+	"github.com/rivo/tview"
+)
+
+// An activeFilter describes one constraint on which requests are shown, for
+// the filters view. Server-side filters are match options in the tap
+// request, so the proxy never reports the requests they exclude and those
+// requests don't count toward --max-rps. Client-side filters are applied by
+// tapshark to requests the proxy has already reported, after --max-rps has
+// limited them.
+type activeFilter struct {
+	name       string
+	value      string
+	serverSide bool
+}
+
+// serverFilters describes the match options of a tap request.
+func serverFilters(params tapPkg.TapRequestParams) []activeFilter {
+	var filters []activeFilter
+	add := func(name, value string) {
+		if value != "" {
+			filters = append(filters, activeFilter{name: name, value: value, serverSide: true})
+		}
+	}
+	add("--to", params.ToResource)
+	add("--to-namespace", params.ToNamespace)
+	add("--scheme", params.Scheme)
+	add("--method", params.Method)
+	add("--authority", params.Authority)
+	add("--path", params.Path)
+	add("--selector", params.LabelSelector)
+	return filters
+}
+
+// clientFilters describes the filters built from options by buildFilters,
+// along with --container and --sample-rate, which are also applied by
+// tapshark.
+func clientFilters(options *options) []activeFilter {
+	var filters []activeFilter
+	add := func(name, value string) {
+		filters = append(filters, activeFilter{name: name, value: value})
+	}
+	if options.tlsOnly {
+		add("--tls-only", "")
+	}
+	if options.plaintextOnly {
+		add("--plaintext-only", "")
+	}
+	if options.grpcStatus != "" {
+		add("--grpc-status", options.grpcStatus)
+	}
+	if options.grpcErrors {
+		add("--grpc-errors", "")
+	}
+	if options.zone != "" {
+		add("--zone", options.zone)
+	}
+	if options.crossZoneOnly {
+		add("--cross-zone-only", "")
+	}
+	for _, header := range options.hasHeaders {
+		add("--has-header", header)
+	}
+	for _, header := range options.notHeaders {
+		add("--not-header", header)
+	}
+	if options.container != "" {
+		add("--container", options.container)
+	}
+	if options.sampleRate < 1 {
+		add("--sample-rate", fmt.Sprintf("%g", options.sampleRate))
+	}
+	return filters
+}
+
+// activeFilters lists every filter in effect: the current tap's match
+// options, then the ones tapshark applies, including the edge filter.
+func (el *eventLog) activeFilters() []activeFilter {
+	var filters []activeFilter
+	if el.session != nil {
+		// The match options are the same for every target.
+		filters = serverFilters(el.session.targets[0])
+	}
+	filters = append(filters, el.clientFilters...)
+	if el.edgeName != "" {
+		filters = append(filters, activeFilter{name: "edge (e)", value: el.edgeName})
+	}
+	return filters
+}
+
+// filterSummary counts the active filters by where they are applied for the
+// status line, or returns nothing if there are none.
+func (el *eventLog) filterSummary() string {
+	var server, client int
+	for _, f := range el.activeFilters() {
+		if f.serverSide {
+			server++
+		} else {
+			client++
+		}
+	}
+	if server+client == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[black:blue] FILTERED [-:-] %d server-side, %d client-side", server, client) + el.keyHint("filters", "list")
+}
+
+// renderFilters lists the active filters and where each is applied, so that
+// requests hidden by tapshark can be told apart from ones never reported.
+func (el *eventLog) renderFilters(table *tview.Table, _ []pkg.Stream) {
+	setHeader(table, "FILTER", "VALUE", "APPLIED", "EFFECT")
+	filters := el.activeFilters()
+	rows := []activeFilter{{name: "--max-rps", value: fmt.Sprintf("%g", el.maxRps), serverSide: true}}
+	rows = append(rows, filters...)
+	for i, f := range rows {
+		applied, effect := "client-side", "hidden after the proxy reports them; still count toward --max-rps"
+		if f.serverSide {
+			applied, effect = "server-side", "never reported by the proxy"
+		}
+		if f.name == "--max-rps" {
+			effect = "requests over the limit are dropped by the proxy, before any client-side filter"
+		}
+		table.SetCellSimple(i+1, 0, f.name)
+		table.SetCellSimple(i+1, 1, strings.TrimSpace(f.value))
+		table.SetCellSimple(i+1, 2, applied)
+		table.SetCellSimple(i+1, 3, effect)
+	}
+	truncateRows(table, len(rows)+1)
+}
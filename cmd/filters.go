@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// A filter reports whether a completed request should be kept. Unlike the
+// match options in the tap request, filters are applied by tapshark after the
+// proxy has already reported the request.
+type filter func(req pkg.Stream) bool
+
+func buildFilters(options *options) ([]filter, error) {
+	var filters []filter
+
+	if options.tlsOnly && options.plaintextOnly {
+		return nil, errors.New("--tls-only and --plaintext-only are mutually exclusive")
+	}
+	if options.tlsOnly {
+		filters = append(filters, func(req pkg.Stream) bool {
+			return tlsStatus(req) == "true"
+		})
+	}
+	if options.plaintextOnly {
+		filters = append(filters, func(req pkg.Stream) bool {
+			return tlsStatus(req) != "true"
+		})
+	}
+
+	if options.grpcStatus != "" && options.grpcErrors {
+		return nil, errors.New("--grpc-status and --grpc-errors are mutually exclusive")
+	}
+	if options.grpcStatus != "" {
+		code, err := parseGrpcStatus(options.grpcStatus)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, func(req pkg.Stream) bool {
+			status, ok := grpcStatus(req)
+			return ok && status == code
+		})
+	}
+	if options.grpcErrors {
+		filters = append(filters, func(req pkg.Stream) bool {
+			status, ok := grpcStatus(req)
+			return ok && status != 0
+		})
+	}
+
+	if name := options.zone; name != "" {
+		filters = append(filters, func(req pkg.Stream) bool {
+			source, destination := zones(req)
+			return source == name || destination == name
+		})
+	}
+	if options.crossZoneOnly {
+		filters = append(filters, crossZone)
+	}
+
+	for _, header := range options.hasHeaders {
+		name, value, matchValue := parseHeaderMatch(header)
+		filters = append(filters, func(req pkg.Stream) bool {
+			return hasHeader(req, name, value, matchValue)
+		})
+	}
+	for _, header := range options.notHeaders {
+		name, value, matchValue := parseHeaderMatch(header)
+		filters = append(filters, func(req pkg.Stream) bool {
+			return !hasHeader(req, name, value, matchValue)
+		})
+	}
+
+	return filters, nil
+}
+
+func (el *eventLog) accept(req pkg.Stream) bool {
+	return acceptAll(el.filters, req)
+}
+
+// sample reports whether to keep a request when only rate of them are shown.
+// Each request is kept independently at random, so that the ones shown are
+// representative of the traffic.
+func sample(rate float64) bool {
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// acceptAll reports whether req passes all of the filters.
+func acceptAll(filters []filter, req pkg.Stream) bool {
+	for _, f := range filters {
+		if !f(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// tlsStatus returns the proxy's tls label for the remote end of the
+// connection. This is "true" when the connection is meshed mTLS.
+func tlsStatus(req pkg.Stream) string {
+	return remoteLabel(req, "tls")
+}
+
+// remoteLabel returns the named label of the metadata for the remote end of
+// the connection: the source of inbound requests and the destination of
+// outbound ones.
+func remoteLabel(req pkg.Stream, name string) string {
+	switch req.Event.GetProxyDirection() {
+	case tapPb.TapEvent_INBOUND:
+		return req.Event.GetSourceMeta().GetLabels()[name]
+	case tapPb.TapEvent_OUTBOUND:
+		return req.Event.GetDestinationMeta().GetLabels()[name]
+	}
+	return ""
+}
+
+// grpcStatus returns the gRPC status the response ended with, if any.
+func grpcStatus(req pkg.Stream) (uint32, bool) {
+	eos, ok := req.RspEnd.GetEos().GetEnd().(*metricsPb.Eos_GrpcStatusCode)
+	if !ok {
+		return 0, false
+	}
+	return eos.GrpcStatusCode, true
+}
+
+// containerFilter keeps the requests served by a container, identified by
+// the ports it declares. Tap doesn't say which container in a pod made or
+// served a request, so inbound requests are matched by their destination
+// port, and outbound requests, whose client can't be told apart, are dropped.
+func containerFilter(ports map[uint32]bool) filter {
+	return func(req pkg.Stream) bool {
+		return req.Event.GetProxyDirection() == tapPb.TapEvent_INBOUND &&
+			ports[req.Event.GetDestination().GetPort()]
+	}
+}
+
+// parseHeaderMatch splits a --has-header or --not-header value of the form
+// name or name=value. matchValue is false if no value was given.
+func parseHeaderMatch(header string) (name, value string, matchValue bool) {
+	if i := strings.Index(header, "="); i >= 0 {
+		return header[:i], header[i+1:], true
+	}
+	return header, "", false
+}
+
+// hasHeader reports whether the request or response headers of req include
+// the named header, with the given value if matchValue is set. Header names
+// are matched without regard to case.
+func hasHeader(req pkg.Stream, name, value string, matchValue bool) bool {
+	for _, headers := range []*metricsPb.Headers{req.ReqInit.GetHeaders(), req.RspInit.GetHeaders()} {
+		for _, header := range headers.GetHeaders() {
+			if strings.EqualFold(header.GetName(), name) && (!matchValue || header.GetValueStr() == value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseGrpcStatus accepts either a gRPC status name, such as UNAVAILABLE, or
+// its numeric code.
+func parseGrpcStatus(status string) (uint32, error) {
+	for code, name := range grpcStatusCodes {
+		if strings.EqualFold(name, status) {
+			return uint32(code), nil
+		}
+	}
+	code, err := strconv.ParseUint(status, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --grpc-status %q; expected a status name such as UNAVAILABLE or a numeric code", status)
+	}
+	return uint32(code), nil
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	log "github.com/sirupsen/logrus"
+)
+
+// captureStats accumulates the figures reported by --stats-interval.
+type captureStats struct {
+	start     time.Time
+	count     int
+	errors    int
+	latencies []time.Duration
+	// recent is the number of requests since the last report.
+	recent     int
+	lastReport time.Time
+}
+
+// runHeadless taps without the UI, writing each accepted request to stdout in
+// the --output format: a line of JSON or a binary record after one describing
+// the capture, or a CSV row after a header row. It returns when the tap stream
+// ends, the limit is reached, no requests have been reported for
+// --idle-timeout, or ctx is done.
+func runHeadless(ctx context.Context, k8sAPI *k8s.KubernetesAPI, reqs []*tapPb.TapByResourceRequest, options *options, filters []filter, capture captureRecord) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Requests from every tap are merged into one stream, which ends once
+	// all of the taps have.
+	requestCh := make(chan pkg.Stream)
+	closed := make(chan struct{})
+	var taps sync.WaitGroup
+	for _, req := range reqs {
+		tapCh, tapClosed, body, err := startTap(ctx, k8sAPI, req, nil, options.saver, func() {})
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		taps.Add(1)
+		go func() {
+			defer taps.Done()
+			for {
+				select {
+				case err := <-tapClosed:
+					if !errors.Is(err, pkg.ErrStreamClosed) && !errors.Is(err, pkg.ErrStreamEnded) {
+						log.Warn(err)
+					}
+					// Requests that completed before the stream
+					// ended may still be buffered.
+					for {
+						select {
+						case req := <-tapCh:
+							select {
+							case requestCh <- req:
+							case <-ctx.Done():
+								return
+							}
+						default:
+							return
+						}
+					}
+				case <-ctx.Done():
+					return
+				case req := <-tapCh:
+					select {
+					case requestCh <- req:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		taps.Wait()
+		close(closed)
+	}()
+
+	start := time.Now()
+	stats := &captureStats{start: start, lastReport: start}
+	var tick <-chan time.Time
+	if options.statsInterval > 0 {
+		ticker := time.NewTicker(options.statsInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+		// The final figures are reported however the capture ends,
+		// including on SIGINT or SIGTERM.
+		defer func() {
+			fmt.Fprintln(os.Stderr, stats.report())
+		}()
+	}
+
+	// The idle timer is restarted whenever a tap reports a request,
+	// whether or not it is kept.
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if options.idleTimeout > 0 {
+		idleTimer = time.NewTimer(options.idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
+	var anonymizer *anonymizer
+	if options.anonymize {
+		anonymizer = newAnonymizer()
+	}
+	recent := newRecentIDs()
+	var seq uint64
+	encoder, err := newRecordEncoder(os.Stdout, options)
+	if err != nil {
+		return err
+	}
+	capture.Capture.Start = start.In(options.location)
+	if err := encoder.Encode(capture); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-closed:
+			return nil
+		case <-tick:
+			fmt.Fprintln(os.Stderr, stats.report())
+		case <-idle:
+			return nil
+		case req := <-requestCh:
+			if idleTimer != nil {
+				if !idleTimer.Stop() {
+					<-idleTimer.C
+				}
+				idleTimer.Reset(options.idleTimeout)
+			}
+			if !acceptAll(filters, req) || !recent.add(req) || !sample(options.sampleRate) {
+				continue
+			}
+			req.Time = time.Now().In(options.location)
+			req.TimestampMs = uint64(req.Time.Sub(start).Milliseconds())
+			seq++
+			req.Seq = seq
+			if streams, ok := encoder.(streamEncoder); ok {
+				err = streams.encodeStream(req)
+			} else {
+				err = encoder.Encode(anonymizer.record(newStreamRecord(req)))
+			}
+			if err != nil {
+				return err
+			}
+			if options.otel != nil {
+				options.otel.export(req, req.Time)
+			}
+			stats.add(req)
+			if options.limit > 0 && stats.count >= options.limit {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *captureStats) add(req pkg.Stream) {
+	s.count++
+	s.recent++
+	if !isSuccess(req) {
+		s.errors++
+	}
+	if req.RspEnd != nil {
+		s.latencies = append(s.latencies, latencyDuration(req))
+	}
+}
+
+// report summarizes the capture so far. The request rate covers only the time
+// since the previous report.
+func (s *captureStats) report() string {
+	now := time.Now()
+	rps := float64(s.recent) / now.Sub(s.lastReport).Seconds()
+	s.recent = 0
+	s.lastReport = now
+
+	var errorRate float64
+	if s.count > 0 {
+		errorRate = 100 * float64(s.errors) / float64(s.count)
+	}
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return fmt.Sprintf("%s: %d requests, %.1f rps, %.1f%% errors, p99 %s",
+		now.Sub(s.start).Round(time.Second), s.count, rps, errorRate, percentile(sorted, 0.99))
+}
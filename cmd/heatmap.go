@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// heatmapGlyphs shade a cell of the heatmap from empty to busiest.
+var heatmapGlyphs = []rune(" ░▒▓█")
+
+// A statusClass is a row of the heatmap.
+type statusClass struct {
+	label string
+	color func(t theme) tcell.Color
+}
+
+var statusClasses = []statusClass{
+	{"2xx", func(t theme) tcell.Color { return t.success }},
+	{"3xx", func(t theme) tcell.Color { return t.redirect }},
+	{"4xx", func(t theme) tcell.Color { return t.clientError }},
+	{"5xx", func(t theme) tcell.Color { return t.serverError }},
+	// Streams that ended without a response, such as those that were
+	// reset.
+	{"-", func(t theme) tcell.Color { return t.noResponse }},
+}
+
+// statusClassIndex returns the index in statusClasses of the row that req is
+// counted in.
+func statusClassIndex(req pkg.Stream) int {
+	if req.RspInit == nil {
+		return len(statusClasses) - 1
+	}
+	switch code := req.RspInit.GetHttpStatus(); {
+	case code < 300:
+		return 0
+	case code < 400:
+		return 1
+	case code < 500:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// renderHeatmap shows how many requests of each status class completed in
+// each time window since the capture started, so that bursts of errors stand
+// out. Shading is relative to the busiest window of any class.
+func (el *eventLog) renderHeatmap(table *tview.Table, events []pkg.Stream) {
+	bucketMs, n := timeBuckets(events)
+	counts := make([][]int, len(statusClasses))
+	for i := range counts {
+		counts[i] = make([]int, n)
+	}
+	totals := make([]int, len(statusClasses))
+	// max starts at 1 so that an empty capture doesn't divide by zero.
+	max := 1
+	for _, req := range events {
+		class := statusClassIndex(req)
+		bucket := req.TimestampMs / bucketMs
+		counts[class][bucket]++
+		totals[class]++
+		if counts[class][bucket] > max {
+			max = counts[class][bucket]
+		}
+	}
+
+	setHeader(table, pad("STATUS"), fmt.Sprintf("%s per column", time.Duration(bucketMs)*time.Millisecond), pad("COUNT"))
+	for i, class := range statusClasses {
+		var line strings.Builder
+		fmt.Fprintf(&line, "[#%06x]", class.color(el.theme).Hex())
+		for _, count := range counts[i] {
+			// Rounding up gives any request at all at least the
+			// lightest shade.
+			glyph := (count*(len(heatmapGlyphs)-1) + max - 1) / max
+			line.WriteRune(heatmapGlyphs[glyph])
+		}
+		line.WriteString("[-]")
+		table.SetCellSimple(i+1, 0, pad(class.label))
+		table.SetCellSimple(i+1, 1, line.String())
+		table.SetCellSimple(i+1, 2, pad(fmt.Sprintf("%d", totals[i])))
+	}
+	table.SetCellSimple(len(statusClasses)+1, 0, "")
+	table.SetCellSimple(len(statusClasses)+1, 1, timeAxis(bucketMs, n))
+	table.SetCellSimple(len(statusClasses)+1, 2, "")
+	truncateRows(table, len(statusClasses)+2)
+}
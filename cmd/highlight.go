@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+)
+
+// defaultHighlightColor is used by --highlight rules that don't name a color.
+// It is none of the colors that statuses are shown in.
+const defaultHighlightColor = tcell.ColorFuchsia
+
+// A highlight shows the rows of requests that match it in bold, in its color.
+type highlight struct {
+	match filter
+	color tcell.Color
+}
+
+// parseHighlight parses a --highlight rule: a comma separated list of
+// conditions, all of which a request must meet, and optionally the color to
+// show it in. For example path=/api,status=5xx,color=red.
+func parseHighlight(rule string) (highlight, error) {
+	h := highlight{color: defaultHighlightColor}
+	var conditions []filter
+	for _, term := range strings.Split(rule, ",") {
+		key, value, ok := parseHeaderMatch(term)
+		if !ok {
+			return highlight{}, fmt.Errorf("invalid --highlight %q: %q is not of the form key=value", rule, term)
+		}
+		switch key {
+		case "color":
+			color := tcell.GetColor(value)
+			if color == tcell.ColorDefault {
+				return highlight{}, fmt.Errorf("invalid --highlight %q: unknown color %q", rule, value)
+			}
+			h.color = color
+		case "path":
+			conditions = append(conditions, func(req pkg.Stream) bool {
+				return strings.HasPrefix(req.ReqInit.GetPath(), value)
+			})
+		case "method":
+			conditions = append(conditions, func(req pkg.Stream) bool {
+				return strings.EqualFold(method(req), value)
+			})
+		case "authority":
+			conditions = append(conditions, func(req pkg.Stream) bool {
+				return req.ReqInit.GetAuthority() == value
+			})
+		case "status":
+			match, err := statusMatcher(value)
+			if err != nil {
+				return highlight{}, fmt.Errorf("invalid --highlight %q: %w", rule, err)
+			}
+			conditions = append(conditions, match)
+		case "header":
+			name, headerValue, matchValue := parseHeaderMatch(value)
+			conditions = append(conditions, func(req pkg.Stream) bool {
+				return hasHeader(req, name, headerValue, matchValue)
+			})
+		default:
+			return highlight{}, fmt.Errorf("invalid --highlight %q: unknown condition %q; use path, method, authority, status, header or color", rule, key)
+		}
+	}
+	if len(conditions) == 0 {
+		return highlight{}, fmt.Errorf("invalid --highlight %q: no conditions", rule)
+	}
+	h.match = func(req pkg.Stream) bool {
+		return acceptAll(conditions, req)
+	}
+	return h, nil
+}
+
+// statusMatcher matches an HTTP status given as a code, such as 503, or a
+// class, such as 5xx.
+func statusMatcher(value string) (filter, error) {
+	if len(value) == 3 && strings.HasSuffix(strings.ToLower(value), "xx") && value[0] >= '1' && value[0] <= '5' {
+		class := uint32(value[0] - '0')
+		return func(req pkg.Stream) bool {
+			return req.RspInit != nil && req.RspInit.GetHttpStatus()/100 == class
+		}, nil
+	}
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || code < 100 || code > 599 {
+		return nil, fmt.Errorf("status %q is not a code such as 503 or a class such as 5xx", value)
+	}
+	return func(req pkg.Stream) bool {
+		return req.RspInit != nil && uint64(req.RspInit.GetHttpStatus()) == code
+	}, nil
+}
+
+func parseHighlights(rules []string) ([]highlight, error) {
+	var highlights []highlight
+	for _, rule := range rules {
+		h, err := parseHighlight(rule)
+		if err != nil {
+			return nil, err
+		}
+		highlights = append(highlights, h)
+	}
+	return highlights, nil
+}
+
+// highlightColor returns the color of the first highlight that req matches.
+func (el *eventLog) highlightColor(req pkg.Stream) (tcell.Color, bool) {
+	for _, h := range el.highlights {
+		if h.match(req) {
+			return h.color, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+)
+
+// htmlDetails formats detail fields as HTML for the expandable rows of an
+// HTML export.
+type htmlDetails struct {
+	io.Writer
+}
+
+func newHTMLDetails(w io.Writer) detailWriter {
+	return htmlDetails{w}
+}
+
+func (w htmlDetails) field(name, value string) {
+	fmt.Fprintf(w, "<div><b>%s:</b> %s</div>\n", template.HTMLEscapeString(name), template.HTMLEscapeString(value))
+}
+
+func (w htmlDetails) list(name string, pairs []detailPair) {
+	fmt.Fprintf(w, "<div><b>%s:</b></div>\n<table class=\"pairs\">\n", template.HTMLEscapeString(name))
+	for _, pair := range pairs {
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td></tr>\n", template.HTMLEscapeString(pair.key), template.HTMLEscapeString(pair.value))
+	}
+	fmt.Fprintln(w, "</table>")
+}
+
+type (
+	htmlPage struct {
+		Title   string
+		Headers []string
+		Rows    []htmlRow
+	}
+
+	htmlRow struct {
+		// StatusColor is the color the TUI shows the status in, as CSS.
+		StatusColor string
+		Cells       []htmlCell
+		Request     template.HTML
+		Response    template.HTML
+	}
+
+	// An htmlCell's Rank is its position when the table is sorted by its
+	// column, so that the page sorts rows the way the TUI does without
+	// reimplementing each column's ordering.
+	htmlCell struct {
+		Text string
+		Rank int
+	}
+)
+
+// writeHTML renders the requests shown in the table as a standalone HTML page
+// with the table's columns, which can be sorted by clicking a header and
+// filtered by text, and each request's details in a row that expands when the
+// request is clicked.
+func (el *eventLog) writeHTML(w io.Writer) error {
+	var events []pkg.Stream
+	for _, req := range el.events {
+		if el.shown(req) {
+			events = append(events, req)
+		}
+	}
+
+	page := htmlPage{Title: el.title, Rows: make([]htmlRow, len(events))}
+	for _, col := range el.columns {
+		page.Headers = append(page.Headers, col.header)
+	}
+	for i, req := range events {
+		var request, response strings.Builder
+		el.writeDetails(req, &request, &response, newHTMLDetails)
+		row := htmlRow{
+			StatusColor: cssColor(el.theme.statusColor(req)),
+			Cells:       make([]htmlCell, len(el.columns)),
+			Request:     template.HTML(request.String()),
+			Response:    template.HTML(response.String()),
+		}
+		for j, col := range el.columns {
+			row.Cells[j].Text = col.value(el, req)
+		}
+		page.Rows[i] = row
+	}
+	order := make([]int, len(events))
+	for j, col := range el.columns {
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return col.compare(el, events[order[a]], events[order[b]])
+		})
+		for rank, i := range order {
+			page.Rows[i].Cells[j].Rank = rank
+		}
+	}
+	return htmlTemplate.Execute(w, page)
+}
+
+// cssColor returns color as a CSS hex color, or inherit for the terminal's
+// default color.
+func cssColor(color tcell.Color) string {
+	if color.Hex() < 0 {
+		return "inherit"
+	}
+	return fmt.Sprintf("#%06x", color.Hex())
+}
+
+// exportHTML writes the requests to an HTML file in the working directory and
+// reports where it went in the status line.
+func (el *eventLog) exportHTML() {
+	path := fmt.Sprintf("tapshark-%s.html", time.Now().Format("20060102-150405"))
+	if err := el.writeHTMLFile(path); err != nil {
+		el.notice = fmt.Sprintf("HTML export failed: %v", err)
+	} else {
+		el.notice = fmt.Sprintf("requests written to %s", path)
+	}
+	el.updateStatus()
+}
+
+func (el *eventLog) writeHTMLFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = el.writeHTML(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+h1 { font-family: monospace; font-size: 1em; }
+#filter { width: 30em; margin-bottom: 1em; }
+table.requests { border-collapse: collapse; font-family: monospace; }
+table.requests th { cursor: pointer; text-align: left; border-bottom: 2px solid #888; padding: 2px 8px; }
+table.requests td { padding: 2px 8px; white-space: nowrap; }
+tr.request { cursor: pointer; }
+tr.request:hover { background: #eef; }
+td.status { color: var(--status); font-weight: bold; }
+tr.details > td { background: #f6f6f6; white-space: normal; }
+.half { display: inline-block; vertical-align: top; width: 48%; padding: 0 1%; }
+table.pairs td { padding: 0 8px; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<input id="filter" type="search" placeholder="Filter requests">
+<table class="requests">
+<thead><tr>{{range $i, $h := .Headers}}<th data-column="{{$i}}">{{$h}}</th>{{end}}</tr></thead>
+{{- range .Rows}}
+<tbody>
+<tr class="request" style="--status: {{.StatusColor}}">{{range .Cells}}<td data-rank="{{.Rank}}">{{.Text}}</td>{{end}}</tr>
+<tr class="details" hidden><td colspan="{{len .Cells}}"><div class="half"><h3>Request</h3>{{.Request}}</div><div class="half"><h3>Response</h3>{{.Response}}</div></td></tr>
+</tbody>
+{{- end}}
+</table>
+<script>
+(function() {
+  var table = document.querySelector("table.requests");
+  var headers = table.querySelectorAll("th");
+  headers.forEach(function(th) {
+    if (th.textContent === "STATUS") {
+      var column = Number(th.dataset.column);
+      table.querySelectorAll("tr.request").forEach(function(tr) {
+        tr.cells[column].classList.add("status");
+      });
+    }
+  });
+  table.addEventListener("click", function(event) {
+    var tr = event.target.closest("tr.request");
+    if (tr) {
+      var details = tr.nextElementSibling;
+      details.hidden = !details.hidden;
+    }
+  });
+  var sorted = -1, descending = false;
+  headers.forEach(function(th) {
+    th.addEventListener("click", function() {
+      var column = Number(th.dataset.column);
+      descending = sorted === column && !descending;
+      sorted = column;
+      var bodies = Array.prototype.slice.call(table.tBodies);
+      bodies.sort(function(a, b) {
+        var d = a.rows[0].cells[column].dataset.rank - b.rows[0].cells[column].dataset.rank;
+        return descending ? -d : d;
+      });
+      bodies.forEach(function(body) { table.appendChild(body); });
+    });
+  });
+  document.getElementById("filter").addEventListener("input", function(event) {
+    var text = event.target.value.toLowerCase();
+    Array.prototype.forEach.call(table.tBodies, function(body) {
+      body.hidden = text !== "" && body.textContent.toLowerCase().indexOf(text) < 0;
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// A marker is an informational row describing a change to one of the tapped
+// pods, shown inline with the requests that arrived around the same time.
+type marker struct {
+	timestampMs uint64
+	text        string
+}
+
+// podWatcher reports the lifecycle of the pods behind the tapped resource as
+// markers. Its handlers are only called from the informer's goroutine.
+type podWatcher struct {
+	el        *eventLog
+	k8sAPI    *k8s.KubernetesAPI
+	namespace string
+	// resource is the TYPE/NAME being tapped, or empty if every pod in
+	// namespace is tapped.
+	resource string
+	// known holds the names of the tapped pods that currently exist.
+	known map[string]bool
+}
+
+// watchPods watches the pods behind resource until done is closed. It should
+// be run in its own goroutine.
+func (el *eventLog) watchPods(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace, resource, labelSelector string, done <-chan struct{}) {
+	defer el.recoverPanic()
+
+	w := &podWatcher{
+		el:     el,
+		k8sAPI: k8sAPI,
+		known:  map[string]bool{},
+	}
+
+	namespace, workload, err := tapTarget(namespace, resource)
+	if err != nil {
+		log.Warnf("Not watching pods: %v", err)
+		return
+	}
+	w.namespace = namespace
+	if workload != "" {
+		w.resource = workload
+		pods, err := k8s.GetPodsFor(ctx, k8sAPI, namespace, workload)
+		if err != nil {
+			log.Warnf("Not watching pods: %v", err)
+			return
+		}
+		for _, pod := range pods {
+			w.known[pod.Name] = true
+		}
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sAPI, 0,
+		informers.WithNamespace(w.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}),
+	)
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.added,
+		UpdateFunc: w.updated,
+		DeleteFunc: w.deleted,
+	})
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		close(stop)
+	}()
+	factory.Start(stop)
+	<-stop
+}
+
+func (w *podWatcher) added(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || w.known[pod.Name] {
+		return
+	}
+	// The informer replays pods that already existed when it started. Those
+	// belonging to a named resource were looked up up front.
+	if pod.CreationTimestamp.Time.Before(w.el.start) {
+		if w.resource == "" {
+			w.known[pod.Name] = true
+		}
+		return
+	}
+	if !w.tapped(pod) {
+		return
+	}
+	w.known[pod.Name] = true
+	w.mark("new pod %s", pod.Name)
+}
+
+func (w *podWatcher) updated(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	pod, ok := newObj.(*corev1.Pod)
+	if !ok || !w.known[pod.Name] {
+		return
+	}
+	if restarts(pod) > restarts(oldPod) {
+		w.mark("pod %s restarted", pod.Name)
+	}
+}
+
+func (w *podWatcher) deleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !w.known[pod.Name] {
+		return
+	}
+	delete(w.known, pod.Name)
+	w.mark("pod %s deleted", pod.Name)
+}
+
+// tapped returns whether a newly created pod belongs to the tapped resource.
+func (w *podWatcher) tapped(pod *corev1.Pod) bool {
+	if w.resource == "" {
+		return true
+	}
+	pods, err := k8s.GetPodsFor(context.Background(), w.k8sAPI, w.namespace, w.resource)
+	if err != nil {
+		log.Warnf("Failed to look up pods for %s: %v", w.resource, err)
+		return false
+	}
+	for _, p := range pods {
+		if p.Name == pod.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *podWatcher) mark(format, pod string) {
+	timestampMs := uint64(time.Since(w.el.start).Milliseconds())
+	w.el.app.QueueUpdateDraw(func() {
+		w.el.addMarker(marker{
+			timestampMs: timestampMs,
+			text:        fmt.Sprintf(format, w.el.anonymizer.pod(pod)),
+		})
+	})
+}
+
+func restarts(pod *corev1.Pod) int32 {
+	var count int32
+	for _, status := range pod.Status.ContainerStatuses {
+		count += status.RestartCount
+	}
+	return count
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A keyAction is something a key can be bound to. It returns the event to
+// pass on to the focused pane, if any.
+type keyAction func(el *eventLog) *tcell.EventKey
+
+// do wraps an action that consumes its key.
+func do(f func(el *eventLog)) keyAction {
+	return func(el *eventLog) *tcell.EventKey {
+		f(el)
+		return nil
+	}
+}
+
+// send returns an action that passes key on to the focused pane, for
+// navigating with keys other than the arrow keys.
+func send(key tcell.Key) keyAction {
+	return func(*eventLog) *tcell.EventKey {
+		return tcell.NewEventKey(key, 0, tcell.ModNone)
+	}
+}
+
+// keyActions are the actions that can be named in a keymap.
+var keyActions = map[string]keyAction{
+	"focus":          do((*eventLog).cycleFocus),
+	"details-down":   do(func(el *eventLog) { el.scrollDetails(1) }),
+	"details-up":     do(func(el *eventLog) { el.scrollDetails(-1) }),
+	"sort":           do((*eventLog).cycleSort),
+	"reverse-sort":   do((*eventLog).reverseSort),
+	"split":          do((*eventLog).toggleSplit),
+	"status-codes":   do(func(el *eventLog) { el.toggleSummary(el.statusCodes) }),
+	"routes":         do(func(el *eventLog) { el.toggleSummary(el.routes) }),
+	"latencies":      do(func(el *eventLog) { el.toggleSummary(el.latencies) }),
+	"connections":    do(func(el *eventLog) { el.toggleSummary(el.connections) }),
+	"heatmap":        do(func(el *eventLog) { el.toggleSummary(el.heatmap) }),
+	"open-streams":   do(func(el *eventLog) { el.toggleSummary(el.openStreams) }),
+	"filters":        do(func(el *eventLog) { el.toggleSummary(el.filterList) }),
+	"grow-details":   do(func(el *eventLog) { el.resizeDetails(detailRatioStep) }),
+	"shrink-details": do(func(el *eventLog) { el.resizeDetails(-detailRatioStep) }),
+	"time":           do(func(el *eventLog) { el.absoluteTime = !el.absoluteTime; el.render() }),
+	"addresses":      do((*eventLog).toggleAddresses),
+	"export-summary": do((*eventLog).exportAggregates),
+	"edit-filters":   do((*eventLog).showFilterForm),
+	"snapshot":       do((*eventLog).writeSnapshot),
+	"html":           do((*eventLog).exportHTML),
+	"latency-bar":    do((*eventLog).toggleLatencyBar),
+	"markdown":       do((*eventLog).exportMarkdown),
+	"edge":           do((*eventLog).toggleEdgeFilter),
+	"traces":         do((*eventLog).toggleTraces),
+	"fold":           do((*eventLog).toggleFold),
+	"headers":        do((*eventLog).showHeaderPicker),
+	"open":           do((*eventLog).openInBrowser),
+	"pause":          do((*eventLog).togglePause),
+	"quit":           do((*eventLog).quit),
+	"search":         do((*eventLog).showSearch),
+	"search-next":    do((*eventLog).searchNext),
+	"down":           send(tcell.KeyDown),
+	"up":             send(tcell.KeyUp),
+	"left":           send(tcell.KeyLeft),
+	"right":          send(tcell.KeyRight),
+	"top":            send(tcell.KeyHome),
+	"bottom":         send(tcell.KeyEnd),
+	"page-down":      send(tcell.KeyPgDn),
+	"page-up":        send(tcell.KeyPgUp),
+}
+
+// defaultKeymap binds keys, named as by keyName, to actions.
+var defaultKeymap = map[string]string{
+	"Tab":    "focus",
+	"Ctrl-D": "details-down",
+	"Ctrl-U": "details-up",
+	"s":      "sort",
+	"S":      "reverse-sort",
+	"d":      "split",
+	"c":      "status-codes",
+	"r":      "routes",
+	"l":      "latencies",
+	"n":      "connections",
+	"h":      "heatmap",
+	"o":      "open-streams",
+	"F":      "filters",
+	"+":      "grow-details",
+	"=":      "grow-details",
+	"-":      "shrink-details",
+	"t":      "time",
+	"a":      "addresses",
+	"x":      "export-summary",
+	"f":      "edit-filters",
+	"w":      "snapshot",
+	"W":      "html",
+	"b":      "latency-bar",
+	"m":      "markdown",
+	"e":      "edge",
+	"T":      "traces",
+	"z":      "fold",
+	"y":      "headers",
+	"O":      "open",
+	"Space":  "pause",
+	"q":      "quit",
+	"/":      "search",
+	"N":      "search-next",
+}
+
+// spaceKey names the space bar, which can't be written on its own in a
+// keymap file.
+const spaceKey = "Space"
+
+// keyName names the key pressed in event: the character typed, Space, or the
+// tcell name of a special key, such as Tab or Ctrl-D.
+func keyName(event *tcell.EventKey) string {
+	if event.Key() == tcell.KeyRune {
+		if event.Rune() == ' ' {
+			return spaceKey
+		}
+		return string(event.Rune())
+	}
+	return tcell.KeyNames[event.Key()]
+}
+
+// validKeyName reports whether name is a single character or the name of a
+// special key.
+func validKeyName(name string) bool {
+	if utf8.RuneCountInString(name) == 1 || name == spaceKey {
+		return true
+	}
+	for _, known := range tcell.KeyNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultKeymapPath is where a keymap is read from if --keymap isn't given.
+func defaultKeymapPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "tapshark", "keymap")
+}
+
+// loadKeymap returns the default bindings, from key names to action names,
+// overridden by those in the keymap file at path, or at defaultKeymapPath if path is empty and that file
+// exists. Each line of the file binds a key to an action, such as
+//
+//	j down
+//	k up
+//	H heatmap
+//
+// Binding a key to none unbinds it. Blank lines and lines starting with #
+// are ignored.
+func loadKeymap(path string) (map[string]string, error) {
+	bindings := make(map[string]string, len(defaultKeymap))
+	for key, action := range defaultKeymap {
+		bindings[key] = action
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultKeymapPath()
+	}
+	if path != "" {
+		file, err := os.Open(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return nil, fmt.Errorf("invalid --keymap: %w", err)
+		default:
+			defer file.Close()
+			if err := parseKeymap(file, path, bindings); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for key, action := range bindings {
+		if action == "none" {
+			delete(bindings, key)
+		}
+	}
+	return bindings, nil
+}
+
+// keyHint tells the user which key does action, as "; press <key> to <what>",
+// for the end of a status message. It is empty if no key is bound to action.
+// If several are, the first in sort order is named.
+func (el *eventLog) keyHint(action, what string) string {
+	var keys []string
+	for key, bound := range el.keymap {
+		if bound == action {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("; press %s to %s", keys[0], what)
+}
+
+func parseKeymap(file *os.File, path string, bindings map[string]string) error {
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected a key and an action", path, line)
+		}
+		key, action := fields[0], fields[1]
+		if !validKeyName(key) {
+			return fmt.Errorf("%s:%d: unknown key %q; use a single character or a name such as Tab or Ctrl-D", path, line, key)
+		}
+		if _, ok := keyActions[action]; !ok && action != "none" {
+			return fmt.Errorf("%s:%d: unknown action %q", path, line, action)
+		}
+		bindings[key] = action
+	}
+	return scanner.Err()
+}
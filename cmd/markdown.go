@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// markdownDetails formats detail fields as a Markdown list, with lists of
+// pairs, such as headers, as tables.
+type markdownDetails struct {
+	io.Writer
+}
+
+func newMarkdownDetails(w io.Writer) detailWriter {
+	return markdownDetails{w}
+}
+
+func (w markdownDetails) field(name, value string) {
+	if value == "" {
+		fmt.Fprintf(w, "- **%s:**\n", name)
+		return
+	}
+	fmt.Fprintf(w, "- **%s:** `%s`\n", name, strings.ReplaceAll(value, "`", "'"))
+}
+
+func (w markdownDetails) list(name string, pairs []detailPair) {
+	if len(pairs) == 0 {
+		fmt.Fprintf(w, "- **%s:** none\n", name)
+		return
+	}
+	fmt.Fprintf(w, "\n**%s**\n\n| Name | Value |\n| --- | --- |\n", name)
+	for _, pair := range pairs {
+		fmt.Fprintf(w, "| %s | %s |\n", markdownCell(pair.key), markdownCell(pair.value))
+	}
+	fmt.Fprintln(w)
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
+
+// exportMarkdown renders the details of the request shown in the details pane
+// as a Markdown document, for pasting into an issue. It is copied to the
+// clipboard and written to a file in the working directory, since not every
+// terminal lets the clipboard be set.
+func (el *eventLog) exportMarkdown() {
+	if el.detailEvent < 0 {
+		return
+	}
+	req := el.events[el.detailEvent]
+
+	var request, response strings.Builder
+	el.writeDetails(req, &request, &response, newMarkdownDetails)
+	doc := fmt.Sprintf("### %s %s\n\n#### Request\n\n%s\n#### Response\n\n%s",
+		req.ReqInit.GetMethod().GetRegistered(), markdownCell(req.ReqInit.GetPath()), request.String(), response.String())
+
+	copyToClipboard(doc)
+	path := fmt.Sprintf("tapshark-%s.md", time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		el.notice = fmt.Sprintf("copied the request as Markdown; writing it failed: %v", err)
+	} else {
+		el.notice = fmt.Sprintf("copied the request as Markdown and wrote it to %s", path)
+	}
+	el.updateStatus()
+}
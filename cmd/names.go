@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// nameResources are the resources of each type that --name-regex can match,
+// by canonical resource name.
+var nameResources = map[string]schema.GroupVersionResource{
+	k8s.Deployment:            {Group: "apps", Version: "v1", Resource: "deployments"},
+	k8s.DaemonSet:             {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	k8s.StatefulSet:           {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	k8s.ReplicaSet:            {Group: "apps", Version: "v1", Resource: "replicasets"},
+	k8s.Job:                   {Group: "batch", Version: "v1", Resource: "jobs"},
+	k8s.CronJob:               {Group: "batch", Version: "v1", Resource: "cronjobs"},
+	k8s.ReplicationController: {Version: "v1", Resource: "replicationcontrollers"},
+	k8s.Pod:                   {Version: "v1", Resource: "pods"},
+}
+
+// resourceNames lists the names of every resource of type typ, a canonical
+// resource name such as deployment, in namespace. Only the resources' metadata
+// is fetched.
+func resourceNames(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace, typ string) ([]string, error) {
+	resource, ok := nameResources[typ]
+	if !ok {
+		return nil, fmt.Errorf("--name-regex can't be used with resources of type %s", typ)
+	}
+	client, err := metadata.NewForConfig(k8sAPI.Config)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.Resource(resource).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(list.Items))
+	for i, item := range list.Items {
+		names[i] = item.Name
+	}
+	return names, nil
+}
+
+// nameTargets replaces each target, which names a resource type such as
+// deploy, with one target for each resource of that type in its namespace
+// whose name matches re.
+func nameTargets(ctx context.Context, k8sAPI *k8s.KubernetesAPI, targets []tapPkg.TapRequestParams, re *regexp.Regexp) ([]tapPkg.TapRequestParams, error) {
+	var matched []tapPkg.TapRequestParams
+	for _, target := range targets {
+		if strings.Contains(target.Resource, "/") {
+			return nil, fmt.Errorf("--name-regex needs a RESOURCE type such as deploy, not %s", target.Resource)
+		}
+		typ, err := k8s.CanonicalResourceNameFromFriendlyName(target.Resource)
+		if err != nil {
+			return nil, err
+		}
+		names, err := resourceNames(ctx, k8sAPI, target.Namespace, typ)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if re.MatchString(name) {
+				t := target
+				t.Resource = typ + "/" + name
+				matched = append(matched, t)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no %s match --name-regex %s", targets[0].Resource, re)
+	}
+	return matched, nil
+}
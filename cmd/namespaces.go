@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// selectNamespaces returns the names of the namespaces matching a label
+// selector.
+func selectNamespaces(ctx context.Context, k8sAPI *k8s.KubernetesAPI, selector string) ([]string, error) {
+	list, err := k8sAPI.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(list.Items))
+	for i, ns := range list.Items {
+		names[i] = ns.Name
+	}
+	return names, nil
+}
+
+// namespaceTargets repeats a tap in each of the given namespaces. If no
+// resource was given, each namespace is tapped as a whole.
+func namespaceTargets(params tapPkg.TapRequestParams, namespaces []string) []tapPkg.TapRequestParams {
+	targets := make([]tapPkg.TapRequestParams, len(namespaces))
+	for i, ns := range namespaces {
+		targets[i] = params
+		targets[i].Namespace = ns
+		if params.Resource == "" {
+			targets[i].Resource = k8s.Namespace + "/" + ns
+		}
+	}
+	return targets
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/linkerd/linkerd2/pkg/addr"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otelShutdownTimeout bounds how long exiting waits for the last spans to be
+// sent.
+const otelShutdownTimeout = 10 * time.Second
+
+// An otelExporter sends completed requests to an OpenTelemetry collector as
+// spans, over OTLP/HTTP. Spans are queued by a batch span processor, which
+// drops them rather than blocking the tap when the collector can't keep up.
+type otelExporter struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// newOTelExporter returns an exporter for the collector at endpoint, which is
+// the base URL of its OTLP/HTTP receiver, such as http://localhost:4318.
+func newOTelExporter(endpoint string) (*otelExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--otel-endpoint must be an http or https URL, such as http://localhost:4318")
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter: %w", err)
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warnf("Failed to export spans: %v", err)
+	}))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("tapshark"))),
+	)
+	return &otelExporter{
+		provider: provider,
+		tracer:   provider.Tracer("tapshark"),
+	}, nil
+}
+
+// shutdown sends the spans that are still queued and stops the exporter.
+func (e *otelExporter) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+	defer cancel()
+	if err := e.provider.Shutdown(ctx); err != nil {
+		log.Warnf("Failed to send the last spans: %v", err)
+	}
+}
+
+// export records a span covering the lifetime of a request, which completed
+// at end. Each request is given a trace of its own.
+func (e *otelExporter) export(req pkg.Stream, end time.Time) {
+	kind := trace.SpanKindClient
+	if req.Event.GetProxyDirection() == tapPb.TapEvent_INBOUND {
+		kind = trace.SpanKindServer
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("http.method", req.ReqInit.GetMethod().GetRegistered().String()),
+		attribute.String("http.scheme", scheme(req)),
+		attribute.String("http.host", req.ReqInit.GetAuthority()),
+		attribute.String("http.target", req.ReqInit.GetPath()),
+		attribute.String("linkerd.source", addr.PublicAddressToString(req.Event.GetSource())),
+		attribute.String("linkerd.destination", addr.PublicAddressToString(req.Event.GetDestination())),
+	}
+	if req.RspInit != nil {
+		attributes = append(attributes, attribute.Int64("http.status_code", int64(req.RspInit.GetHttpStatus())))
+	}
+	if pod := req.Event.GetSourceMeta().GetLabels()["pod"]; pod != "" {
+		attributes = append(attributes, attribute.String("linkerd.source.pod", pod))
+	}
+	if pod := req.Event.GetDestinationMeta().GetLabels()["pod"]; pod != "" {
+		attributes = append(attributes, attribute.String("linkerd.destination.pod", pod))
+	}
+
+	_, span := e.tracer.Start(context.Background(),
+		fmt.Sprintf("%s %s", req.ReqInit.GetMethod().GetRegistered(), req.ReqInit.GetPath()),
+		trace.WithNewRoot(),
+		trace.WithSpanKind(kind),
+		trace.WithTimestamp(end.Add(-latencyDuration(req))),
+		trace.WithAttributes(attributes...),
+	)
+	if !isSuccess(req) {
+		span.SetStatus(codes.Error, "")
+	}
+	span.End(trace.WithTimestamp(end))
+}
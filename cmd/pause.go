@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// showRequest adds a completed request to the event list, from a tap's
+// goroutine. While the view is paused the request is held back from the table
+// until it resumes, and no draw is queued for it, so that the table stays
+// still.
+func (el *eventLog) showRequest(req pkg.Stream) {
+	el.pauseMu.Lock()
+	paused := el.paused
+	el.pauseMu.Unlock()
+
+	// The table and event list are only touched from the UI goroutine.
+	// Numbering requests on the UI goroutine gives them the same order as
+	// the table, whichever tap they came from. The view may be paused or
+	// resumed before the update runs, so addEvent checks again there.
+	update := func() {
+		el.addRequest(req)
+	}
+	if paused {
+		el.app.QueueUpdate(update)
+	} else {
+		el.app.QueueUpdateDraw(update)
+	}
+}
+
+func (el *eventLog) addRequest(req pkg.Stream) {
+	el.seq++
+	req.Seq = el.seq
+	el.addEvent(req)
+}
+
+// togglePause freezes the table, or resumes it by adding every request that
+// completed while it was frozen.
+func (el *eventLog) togglePause() {
+	el.pauseMu.Lock()
+	el.paused = !el.paused
+	el.pauseMu.Unlock()
+
+	if !el.paused {
+		n := el.flushBacklog()
+		el.notice = fmt.Sprintf("resumed; %d requests completed while paused", n)
+		el.updateStatus()
+	}
+	el.updateTitle()
+}
+
+// flushBacklog adds the requests held back while the view was paused to the
+// table and returns how many there were.
+func (el *eventLog) flushBacklog() int {
+	n := el.held
+	// Each request leaves the backlog before it is shown, since render
+	// shows every request that isn't held.
+	for el.held > 0 {
+		idx := len(el.events) - el.held
+		el.held--
+		el.showEvent(idx)
+	}
+	return n
+}
+
+// updateTitle shows the command line in the grid's title, after a PAUSED
+// badge while the view is paused.
+func (el *eventLog) updateTitle() {
+	if el.paused {
+		el.grid.SetTitle("[black:yellow] PAUSED [-:-] " + el.title)
+	} else {
+		el.grid.SetTitle(el.title)
+	}
+}
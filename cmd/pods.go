@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tapTarget resolves the tapped resource to the namespace its pods are in and,
+// if it names a single workload, its TYPE/NAME. An empty workload means every
+// pod in the namespace is tapped.
+func tapTarget(namespace, resource string) (string, string, error) {
+	elems := strings.Split(resource, "/")
+	if len(elems) != 2 {
+		return namespace, "", nil
+	}
+	typ, err := k8s.CanonicalResourceNameFromFriendlyName(elems[0])
+	if err != nil {
+		return "", "", err
+	}
+	if typ == k8s.Namespace {
+		return elems[1], "", nil
+	}
+	return namespace, resource, nil
+}
+
+// tappedPods lists the pods behind the tapped resource.
+func tappedPods(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace, resource, labelSelector string) ([]corev1.Pod, error) {
+	namespace, workload, err := tapTarget(namespace, resource)
+	if err != nil {
+		return nil, err
+	}
+	if workload != "" {
+		return k8s.GetPodsFor(ctx, k8sAPI, namespace, workload)
+	}
+	pods, err := k8sAPI.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// checkMeshed returns a warning if none of the tapped pods have a proxy, since
+// tap would then never report any requests.
+func checkMeshed(ctx context.Context, k8sAPI *k8s.KubernetesAPI, options *options, resource string) (string, error) {
+	pods, err := tappedPods(ctx, k8sAPI, options.namespace, resource, options.labelSelector)
+	if err != nil {
+		return "", err
+	}
+	for i := range pods {
+		if k8s.IsMeshed(&pods[i], options.controlPlaneNamespace) {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("%s has no meshed pods; tap will produce no events", resource), nil
+}
+
+// containerPorts returns the ports declared by the named container in any of
+// the tapped pods.
+func containerPorts(ctx context.Context, k8sAPI *k8s.KubernetesAPI, namespace, resource, labelSelector, container string) (map[uint32]bool, error) {
+	pods, err := tappedPods(ctx, k8sAPI, namespace, resource, labelSelector)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	ports := map[uint32]bool{}
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			if c.Name != container {
+				continue
+			}
+			found = true
+			for _, port := range c.Ports {
+				ports[uint32(port.ContainerPort)] = true
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("none of the tapped pods have a container named %s", container)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("container %s declares no ports, so its requests can't be told apart", container)
+	}
+	return ports, nil
+}
+
+// parseTargetIP returns the IP address in target, which may be a bare IP or an
+// ip:port endpoint. ok is false if target is not an IP address.
+func parseTargetIP(target string) (ip string, ok bool) {
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		target = host
+	}
+	if net.ParseIP(target) == nil {
+		return "", false
+	}
+	return target, true
+}
+
+// resolvePodIP returns the namespace and pod/NAME of the meshed pod with the
+// given IP address.
+func resolvePodIP(ctx context.Context, k8sAPI *k8s.KubernetesAPI, ip, controlPlaneNamespace string) (string, string, error) {
+	pods, err := k8sAPI.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.podIP=" + ip})
+	if err != nil {
+		return "", "", err
+	}
+	var unmeshed []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		// Pods on the host network share the node's IP, so it doesn't
+		// identify them.
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		if !k8s.IsMeshed(pod, controlPlaneNamespace) {
+			unmeshed = append(unmeshed, pod.Namespace+"/"+pod.Name)
+			continue
+		}
+		return pod.Namespace, "pod/" + pod.Name, nil
+	}
+	if len(unmeshed) > 0 {
+		return "", "", fmt.Errorf("%s belongs to %s, which is not meshed", ip, strings.Join(unmeshed, ", "))
+	}
+	return "", "", fmt.Errorf("no pod has the IP address %s", ip)
+}
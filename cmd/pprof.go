@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"net/http"
+	// Registers the profiling handlers on http.DefaultServeMux.
+	_ "net/http/pprof"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// servePprof serves the net/http/pprof handlers on addr in the background, for
+// profiling tapshark itself.
+func servePprof(addr string) {
+	go func() {
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			log.Errorf("Failed to serve pprof on %s: %v", addr, err)
+		}
+	}()
+}
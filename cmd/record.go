@@ -0,0 +1,445 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/golang/protobuf/ptypes"
+	netPb "github.com/linkerd/linkerd2/controller/gen/common/net"
+	"github.com/linkerd/linkerd2/pkg/addr"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// recordSchemaVersion is the version of the streamRecord format. It must be
+// incremented whenever a field is removed or its meaning changes; adding a
+// field does not require a new version.
+//
+// Version 2 added the captureRecord that starts a capture.
+const recordSchemaVersion = 2
+
+type (
+	// A streamRecord is the JSON representation of a completed request. One
+	// record is written per line. The field names are part of tapshark's
+	// output format and are kept stable even if pkg.Stream or the tap API
+	// change.
+	streamRecord struct {
+		// SchemaVersion is the recordSchemaVersion the record was written
+		// with. Records written before versioning was added have none.
+		SchemaVersion int `json:"schemaVersion"`
+		// TimestampMs is when the request completed, in milliseconds since
+		// the capture started.
+		TimestampMs uint64 `json:"timestampMs"`
+		// Seq numbers the records in the order the requests were
+		// received, from 1, which orders those with the same
+		// TimestampMs. Records written by older versions of tapshark
+		// have none.
+		Seq uint64 `json:"seq,omitempty"`
+		// Time is the wall clock time the request completed. Records
+		// written by older versions of tapshark have none.
+		Time *time.Time `json:"time,omitempty"`
+		// Direction is INBOUND or OUTBOUND, from the point of view of the
+		// proxy that reported the request, or UNKNOWN.
+		Direction string `json:"direction"`
+		// Source and Destination are the ip:port of the client and server.
+		// Their metadata are the labels the proxy attached to each, such as
+		// pod, namespace and tls.
+		Source          string            `json:"source"`
+		SourceMeta      map[string]string `json:"sourceMeta,omitempty"`
+		Destination     string            `json:"destination"`
+		DestinationMeta map[string]string `json:"destinationMeta,omitempty"`
+		// RouteMeta holds the labels of the service profile route the
+		// request matched, if any.
+		RouteMeta map[string]string `json:"routeMeta,omitempty"`
+		// Scheme, Method, Authority and Path are taken from the request
+		// line. Scheme and Method are upper case names, such as HTTP and
+		// GET, unless the method was non-standard.
+		Scheme         string         `json:"scheme"`
+		Method         string         `json:"method"`
+		Authority      string         `json:"authority"`
+		Path           string         `json:"path"`
+		RequestHeaders []headerRecord `json:"requestHeaders,omitempty"`
+		// Status is the HTTP status of the response. It is omitted if the
+		// stream ended before response headers were received.
+		Status          *uint32        `json:"status,omitempty"`
+		ResponseHeaders []headerRecord `json:"responseHeaders,omitempty"`
+		// Latency is the time from the start of the request until the
+		// response ended, and Duration the time from the response headers
+		// until it ended, both as Go durations such as "1.5ms".
+		Latency  string `json:"latency"`
+		Duration string `json:"duration"`
+		// ResponseBytes is the size of the response body.
+		ResponseBytes uint64         `json:"responseBytes"`
+		Trailers      []headerRecord `json:"trailers,omitempty"`
+		// At most one of GrpcStatus and ResetErrorCode is set: the gRPC
+		// status the response ended with, or the HTTP/2 error code the
+		// stream was reset with.
+		GrpcStatus     *uint32 `json:"grpcStatus,omitempty"`
+		ResetErrorCode *uint32 `json:"resetErrorCode,omitempty"`
+		// Incomplete is set if the request never ended, such as when its
+		// stream ID was reused. Its latency and duration are then empty.
+		Incomplete bool `json:"incomplete,omitempty"`
+	}
+
+	// A headerRecord is a single header or trailer. Binary values are not
+	// preserved.
+	headerRecord struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+)
+
+var (
+	// gzipMagic is the header that every gzip file starts with.
+	gzipMagic = []byte{0x1f, 0x8b}
+	// binaryMagic starts every capture written with --output binary. It is
+	// followed by a gob stream of the same records as the JSON format,
+	// which is several times smaller since field names are only written
+	// once.
+	binaryMagic = []byte("tapshark-gob\n")
+)
+
+type captureFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f *captureFile) Close() error {
+	var err error
+	for _, c := range f.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openCapture opens a saved capture, decompressing it if it was gzipped.
+func openCapture(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		// Files too short to be gzipped are read as they are.
+		return &captureFile{Reader: reader, closers: []io.Closer{file}}, nil
+	}
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return &captureFile{Reader: gz, closers: []io.Closer{gz, file}}, nil
+}
+
+// newStreamRecord flattens a completed request into a record.
+func newStreamRecord(req pkg.Stream) streamRecord {
+	record := streamRecord{
+		SchemaVersion:   recordSchemaVersion,
+		TimestampMs:     req.TimestampMs,
+		Seq:             req.Seq,
+		Direction:       req.Event.GetProxyDirection().String(),
+		Source:          addr.PublicAddressToString(req.Event.GetSource()),
+		SourceMeta:      req.Event.GetSourceMeta().GetLabels(),
+		Destination:     addr.PublicAddressToString(req.Event.GetDestination()),
+		DestinationMeta: req.Event.GetDestinationMeta().GetLabels(),
+		RouteMeta:       req.Event.GetRouteMeta().GetLabels(),
+		Scheme:          scheme(req),
+		Method:          method(req),
+		Authority:       req.ReqInit.GetAuthority(),
+		Path:            req.ReqInit.GetPath(),
+		RequestHeaders:  headerRecords(req.ReqInit.GetHeaders()),
+		Latency:         latency(req),
+		ResponseBytes:   req.RspEnd.GetResponseBytes(),
+		Trailers:        headerRecords(req.RspEnd.GetTrailers()),
+		Incomplete:      req.RspEnd == nil,
+	}
+	if d, err := ptypes.Duration(req.RspEnd.GetSinceResponseInit()); err == nil {
+		record.Duration = d.String()
+	}
+	if !req.Time.IsZero() {
+		t := req.Time
+		record.Time = &t
+	}
+	if req.RspInit != nil {
+		status := req.RspInit.GetHttpStatus()
+		record.Status = &status
+		record.ResponseHeaders = headerRecords(req.RspInit.GetHeaders())
+	}
+	switch eos := req.RspEnd.GetEos().GetEnd().(type) {
+	case *metricsPb.Eos_GrpcStatusCode:
+		record.GrpcStatus = &eos.GrpcStatusCode
+	case *metricsPb.Eos_ResetErrorCode:
+		record.ResetErrorCode = &eos.ResetErrorCode
+	}
+	return record
+}
+
+func headerRecords(headers *metricsPb.Headers) []headerRecord {
+	var records []headerRecord
+	for _, header := range headers.GetHeaders() {
+		records = append(records, headerRecord{Name: header.GetName(), Value: header.GetValueStr()})
+	}
+	return records
+}
+
+// A recordEncoder writes each record of a capture: a captureRecord and then a
+// streamRecord per request.
+type recordEncoder interface {
+	Encode(record interface{}) error
+}
+
+// A streamEncoder is a recordEncoder that writes each request from the
+// pkg.Stream itself rather than from its streamRecord, which doesn't keep
+// everything a request holds, such as binary header values.
+type streamEncoder interface {
+	recordEncoder
+	encodeStream(req pkg.Stream) error
+}
+
+// newRecordEncoder returns an encoder that writes records to w in the
+// --output format: json, binary or csv.
+func newRecordEncoder(w io.Writer, options *options) (recordEncoder, error) {
+	switch options.output {
+	case "csv":
+		return newCSVEncoder(w, options), nil
+	case "binary":
+	default:
+		return json.NewEncoder(w), nil
+	}
+	if _, err := w.Write(binaryMagic); err != nil {
+		return nil, err
+	}
+	return gob.NewEncoder(w), nil
+}
+
+// readCapture reads the capture at path, as JSON lines or in the binary
+// format, back into streams, along with the metadata of the capture if it was
+// saved with any.
+func readCapture(path string) ([]pkg.Stream, *captureMetadata, error) {
+	file, err := openCapture(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if magic, err := reader.Peek(len(binaryMagic)); err == nil && bytes.Equal(magic, binaryMagic) {
+		reader.Discard(len(binaryMagic))
+		streams, metadata, err := readBinary(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return streams, metadata, nil
+	}
+
+	var streams []pkg.Stream
+	var metadata *captureMetadata
+	decoder := json.NewDecoder(reader)
+	for {
+		var line json.RawMessage
+		if err := decoder.Decode(&line); err == io.EOF {
+			return streams, metadata, nil
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var capture captureRecord
+		if err := json.Unmarshal(line, &capture); err != nil {
+			return nil, nil, fmt.Errorf("invalid record in %s: %w", path, err)
+		}
+		if capture.SchemaVersion > recordSchemaVersion {
+			return nil, nil, fmt.Errorf("%s was written by a newer version of tapshark (schema version %d)", path, capture.SchemaVersion)
+		}
+		if capture.Capture != nil {
+			metadata = capture.Capture
+			continue
+		}
+		var record streamRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, nil, fmt.Errorf("invalid record in %s: %w", path, err)
+		}
+		req, err := record.stream()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid record in %s: %w", path, err)
+		}
+		streams = append(streams, req)
+	}
+}
+
+// readBinary reads a capture in the binary format, after its magic.
+func readBinary(r io.Reader) ([]pkg.Stream, *captureMetadata, error) {
+	decoder := gob.NewDecoder(r)
+	var capture captureRecord
+	if err := decoder.Decode(&capture); err != nil {
+		return nil, nil, err
+	}
+	if capture.SchemaVersion > recordSchemaVersion {
+		return nil, nil, fmt.Errorf("written by a newer version of tapshark (schema version %d)", capture.SchemaVersion)
+	}
+
+	var streams []pkg.Stream
+	for {
+		var record streamRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			return streams, capture.Capture, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		req, err := record.stream()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid record: %w", err)
+		}
+		streams = append(streams, req)
+	}
+}
+
+// stream rebuilds the tap events that a record was created from. Binary
+// header values are not preserved.
+func (r *streamRecord) stream() (pkg.Stream, error) {
+	source, err := parseTCPAddress(r.Source)
+	if err != nil {
+		return pkg.Stream{}, err
+	}
+	destination, err := parseTCPAddress(r.Destination)
+	if err != nil {
+		return pkg.Stream{}, err
+	}
+	latency, err := parseDuration(r.Latency)
+	if err != nil {
+		return pkg.Stream{}, err
+	}
+	duration, err := parseDuration(r.Duration)
+	if err != nil {
+		return pkg.Stream{}, err
+	}
+
+	reqInit := &tapPb.TapEvent_Http_RequestInit{
+		Method:    parseMethod(r.Method),
+		Scheme:    parseScheme(r.Scheme),
+		Authority: r.Authority,
+		Path:      r.Path,
+		Headers:   parseHeaders(r.RequestHeaders),
+	}
+
+	req := pkg.Stream{
+		Event: &tapPb.TapEvent{
+			Source:          source,
+			SourceMeta:      &tapPb.TapEvent_EndpointMeta{Labels: r.SourceMeta},
+			Destination:     destination,
+			DestinationMeta: &tapPb.TapEvent_EndpointMeta{Labels: r.DestinationMeta},
+			RouteMeta:       &tapPb.TapEvent_RouteMeta{Labels: r.RouteMeta},
+			ProxyDirection:  tapPb.TapEvent_ProxyDirection(tapPb.TapEvent_ProxyDirection_value[r.Direction]),
+			Event: &tapPb.TapEvent_Http_{
+				Http: &tapPb.TapEvent_Http{
+					Event: &tapPb.TapEvent_Http_RequestInit_{RequestInit: reqInit},
+				},
+			},
+		},
+		ReqInit:     reqInit,
+		TimestampMs: r.TimestampMs,
+		Seq:         r.Seq,
+	}
+	if r.Time != nil {
+		req.Time = *r.Time
+	}
+	if r.Status != nil {
+		req.RspInit = &tapPb.TapEvent_Http_ResponseInit{
+			HttpStatus: *r.Status,
+			Headers:    parseHeaders(r.ResponseHeaders),
+		}
+	}
+	if r.Incomplete {
+		return req, nil
+	}
+	req.RspEnd = &tapPb.TapEvent_Http_ResponseEnd{
+		SinceRequestInit:  ptypes.DurationProto(latency),
+		SinceResponseInit: ptypes.DurationProto(duration),
+		ResponseBytes:     r.ResponseBytes,
+		Trailers:          parseHeaders(r.Trailers),
+	}
+	if r.GrpcStatus != nil {
+		req.RspEnd.Eos = &metricsPb.Eos{End: &metricsPb.Eos_GrpcStatusCode{GrpcStatusCode: *r.GrpcStatus}}
+	} else if r.ResetErrorCode != nil {
+		req.RspEnd.Eos = &metricsPb.Eos{End: &metricsPb.Eos_ResetErrorCode{ResetErrorCode: *r.ResetErrorCode}}
+	}
+	return req, nil
+}
+
+func parseTCPAddress(address string) (*netPb.TcpAddress, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %s", address)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address in %s", address)
+	}
+
+	tcpAddress := &netPb.TcpAddress{Port: uint32(portNum)}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		tcpAddress.Ip = &netPb.IPAddress{
+			Ip: &netPb.IPAddress_Ipv4{Ipv4: binary.BigEndian.Uint32(ipv4)},
+		}
+	} else {
+		tcpAddress.Ip = &netPb.IPAddress{
+			Ip: &netPb.IPAddress_Ipv6{Ipv6: &netPb.IPv6{
+				First: binary.BigEndian.Uint64(ip[:8]),
+				Last:  binary.BigEndian.Uint64(ip[8:]),
+			}},
+		}
+	}
+	return tcpAddress, nil
+}
+
+func parseDuration(d string) (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(d)
+}
+
+func parseMethod(method string) *metricsPb.HttpMethod {
+	if registered, ok := metricsPb.HttpMethod_Registered_value[method]; ok {
+		return &metricsPb.HttpMethod{
+			Type: &metricsPb.HttpMethod_Registered_{Registered: metricsPb.HttpMethod_Registered(registered)},
+		}
+	}
+	return &metricsPb.HttpMethod{Type: &metricsPb.HttpMethod_Unregistered{Unregistered: method}}
+}
+
+func parseScheme(scheme string) *metricsPb.Scheme {
+	if registered, ok := metricsPb.Scheme_Registered_value[scheme]; ok {
+		return &metricsPb.Scheme{
+			Type: &metricsPb.Scheme_Registered_{Registered: metricsPb.Scheme_Registered(registered)},
+		}
+	}
+	return &metricsPb.Scheme{Type: &metricsPb.Scheme_Unregistered{Unregistered: scheme}}
+}
+
+func parseHeaders(headers []headerRecord) *metricsPb.Headers {
+	parsed := &metricsPb.Headers{}
+	for _, header := range headers {
+		parsed.Headers = append(parsed.Headers, &metricsPb.Headers_Header{
+			Name:  header.Name,
+			Value: &metricsPb.Headers_Header_ValueStr{ValueStr: header.Value},
+		})
+	}
+	return parsed
+}
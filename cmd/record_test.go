@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// TestStreamRecordIncomplete checks that a request that never ended is read
+// back from its record as incomplete, rather than as a request that took 0s.
+func TestStreamRecordIncomplete(t *testing.T) {
+	event := tapRequestInit(1)
+	record := newStreamRecord(pkg.Stream{Event: event, ReqInit: event.GetHttp().GetRequestInit()})
+	req, err := record.stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.RspEnd != nil {
+		t.Fatalf("expected no response end, got %v", req.RspEnd)
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// replayHeaders are request headers that aren't copied when a request is
+// replayed: pseudo-headers are rebuilt from the request line, and the rest
+// describe a body or connection that the replay doesn't have.
+var replayHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"transfer-encoding": true,
+	"connection":        true,
+	"keep-alive":        true,
+	"upgrade":           true,
+	"te":                true,
+}
+
+// replayRequests sends each captured request to target, a base URL such as
+// http://localhost:8080, starting them as far apart as they originally started
+// divided by speed. The method, path, :authority (as the Host header) and
+// request headers are reproduced; bodies aren't captured, so every request is
+// sent without one. Each response is reported on stdout as it arrives, and a
+// summary once every request has finished or ctx is done.
+func replayRequests(ctx context.Context, events []pkg.Stream, target string, speed float64) error {
+	base, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid --replay-to: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return fmt.Errorf("--replay-to must be an http:// or https:// URL")
+	}
+	target = strings.TrimSuffix(target, "/")
+
+	// Requests are replayed in the order they started, which is when they
+	// completed less their latency.
+	starts := make([]time.Duration, len(events))
+	order := make([]int, len(events))
+	for i, req := range events {
+		starts[i] = time.Duration(req.TimestampMs)*time.Millisecond - latencyDuration(req)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return starts[order[i]] < starts[order[j]] })
+
+	client := &http.Client{
+		// Redirects are reported rather than followed, as they were in
+		// the capture.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	start := time.Now()
+	stats := &captureStats{start: start, lastReport: start}
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	defer func() {
+		wg.Wait()
+		fmt.Fprintf(os.Stderr, "%s; %d failed to send\n", stats.report(), failed)
+	}()
+
+	for _, i := range order {
+		offset := starts[i] - starts[order[0]]
+		wait := time.Until(start.Add(time.Duration(float64(offset) / speed)))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		req := events[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent := time.Now()
+			code, err := replayRequest(ctx, client, target, req)
+			elapsed := time.Since(sent)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				fmt.Printf("%s %s: %v\n", method(req), req.ReqInit.GetPath(), err)
+				return
+			}
+			fmt.Printf("%s %s: %d in %s (captured %s in %s)\n",
+				method(req), req.ReqInit.GetPath(), code, elapsed.Round(time.Microsecond), status(req), latency(req))
+			stats.count++
+			stats.recent++
+			if code >= 500 {
+				stats.errors++
+			}
+			stats.latencies = append(stats.latencies, elapsed)
+		}()
+	}
+	return nil
+}
+
+// replayRequest sends req to target and returns the status of the response,
+// whose body is discarded.
+func replayRequest(ctx context.Context, client *http.Client, target string, req pkg.Stream) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method(req), target+req.ReqInit.GetPath(), nil)
+	if err != nil {
+		return 0, err
+	}
+	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+		name := header.GetName()
+		if strings.HasPrefix(name, ":") || replayHeaders[strings.ToLower(name)] {
+			continue
+		}
+		httpReq.Header.Add(name, header.GetValueStr())
+	}
+	if authority := req.ReqInit.GetAuthority(); authority != "" {
+		httpReq.Host = authority
+	}
+	rsp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	_, err = io.Copy(io.Discard, rsp.Body)
+	return rsp.StatusCode, err
+}
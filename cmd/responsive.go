@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// columnHidePriority lists the columns that --responsive may hide, in the
+// order they are hidden as the terminal narrows. PATH, STATUS, and LATENCY
+// are always shown.
+var columnHidePriority = []string{
+	"SEQ",
+	"REQ-HDRS",
+	"RSP-HDRS",
+	"TO-SVC",
+	"ZONE",
+	"SCHEME",
+	latencyBarHeader,
+	"CLASS",
+	"FROM",
+	"TO",
+	"POD",
+	"VERB",
+	"TIME",
+}
+
+// columnText records the width of text in the column with the given header
+// and returns what to show in its place: text itself, or nothing if the
+// column is hidden.
+func (el *eventLog) columnText(header, text string) string {
+	if !el.responsive {
+		return text
+	}
+	if width := tview.TaggedStringWidth(text); width > el.columnWidths[header] {
+		el.columnWidths[header] = width
+	}
+	if el.hiddenColumns[header] {
+		return ""
+	}
+	return text
+}
+
+// fitColumns hides the lowest priority columns that don't fit in the width of
+// screen, and shows them again once they do. It is called before every draw
+// so that it follows the terminal as it is resized.
+func (el *eventLog) fitColumns(screen tcell.Screen) {
+	if !el.responsive {
+		return
+	}
+	width, _ := screen.Size()
+	// The grid's borders take a column on either side.
+	width -= 2
+
+	// Every column, even an empty one, is followed by a space.
+	needed := 0
+	for _, col := range el.columns {
+		needed += el.columnWidths[col.header] + 1
+	}
+	hidden := map[string]bool{}
+	for _, header := range columnHidePriority {
+		if needed <= width {
+			break
+		}
+		if !el.showsColumn(header) {
+			continue
+		}
+		hidden[header] = true
+		needed -= el.columnWidths[header]
+	}
+
+	if len(hidden) == len(el.hiddenColumns) {
+		same := true
+		for header := range hidden {
+			same = same && el.hiddenColumns[header]
+		}
+		if same {
+			return
+		}
+	}
+	el.hiddenColumns = hidden
+	el.render()
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linkerd/linkerd2/pkg/k8s"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
+	"github.com/rivo/tview"
+)
+
+// A tapSession is the tap stream currently feeding the event log.
+type tapSession struct {
+	ctx    context.Context
+	k8sAPI *k8s.KubernetesAPI
+	// targets holds a tap for each namespace tapped.
+	targets []tapPkg.TapRequestParams
+	cancel  context.CancelFunc
+}
+
+// startSession starts tapping targets, replacing any taps already running.
+func (el *eventLog) startSession(ctx context.Context, k8sAPI *k8s.KubernetesAPI, targets []tapPkg.TapRequestParams) error {
+	var reqs []*tapPb.TapByResourceRequest
+	for _, target := range targets {
+		req, err := tapPkg.BuildTapByResourceRequest(target)
+		if err != nil {
+			return err
+		}
+		reqs = append(reqs, req)
+	}
+	if el.session != nil {
+		el.session.cancel()
+	}
+	el.tapError = ""
+	tapCtx, cancel := context.WithCancel(ctx)
+	el.session = &tapSession{ctx: ctx, k8sAPI: k8sAPI, targets: targets, cancel: cancel}
+	for _, req := range reqs {
+		req := req
+		el.goUpdating(func() { el.processTapEvents(tapCtx, k8sAPI, req, el.done) })
+	}
+	return nil
+}
+
+// showFilterForm opens a form for editing the current tap's match options.
+// Submitting it restarts the tap with the new options.
+func (el *eventLog) showFilterForm() {
+	if el.session == nil {
+		return
+	}
+	// The filters are the same for every target.
+	params := el.session.targets[0]
+	clear := false
+
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle(" Tap filters ")
+	form.AddInputField("To", params.ToResource, 0, nil, func(text string) { params.ToResource = text }).
+		AddInputField("To namespace", params.ToNamespace, 0, nil, func(text string) { params.ToNamespace = text }).
+		AddInputField("Scheme", params.Scheme, 0, nil, func(text string) { params.Scheme = text }).
+		AddInputField("Method", params.Method, 0, nil, func(text string) { params.Method = text }).
+		AddInputField("Authority", params.Authority, 0, nil, func(text string) { params.Authority = text }).
+		AddInputField("Path", params.Path, 0, nil, func(text string) { params.Path = text }).
+		AddInputField("Selector", params.LabelSelector, 0, nil, func(text string) { params.LabelSelector = text }).
+		AddCheckbox("Clear history", clear, func(checked bool) { clear = checked }).
+		AddButton("Apply", func() {
+			targets := make([]tapPkg.TapRequestParams, len(el.session.targets))
+			for i, target := range el.session.targets {
+				targets[i] = params
+				targets[i].Resource = target.Resource
+				targets[i].Namespace = target.Namespace
+			}
+			if err := el.startSession(el.session.ctx, el.session.k8sAPI, targets); err != nil {
+				el.warning = fmt.Sprintf("tap not restarted: %v", err)
+			} else {
+				el.warning = ""
+				if clear {
+					el.clearHistory()
+				}
+			}
+			el.closeOverlay()
+		}).
+		AddButton("Cancel", el.closeOverlay).
+		SetCancelFunc(el.closeOverlay)
+
+	el.showOverlay(form)
+}
+
+// showOverlay replaces the whole UI with p, such as a form, until
+// closeOverlay is called. Key bindings are suspended in the meantime.
+func (el *eventLog) showOverlay(p tview.Primitive) {
+	el.editing = true
+	el.app.SetRoot(p, true).SetFocus(p)
+}
+
+func (el *eventLog) closeOverlay() {
+	el.editing = false
+	el.updateStatus()
+	el.app.SetRoot(el.root, true)
+	el.app.SetFocus(el.panes()[0])
+}
+
+// clearHistory forgets every request and marker captured so far.
+func (el *eventLog) clearHistory() {
+	el.events = el.events[:0]
+	el.repeats = el.repeats[:0]
+	el.held = 0
+	el.collapsed = map[string]*collapsedRow{}
+	el.markers = el.markers[:0]
+	el.sources = map[string]struct{}{}
+	el.destinations = map[string]struct{}{}
+	el.requestDetails.Clear()
+	el.responseDetails.Clear()
+	el.detailEvent = -1
+	if el.summary != nil {
+		el.summary.render(el.summary.table, el.events)
+	}
+	el.render()
+	el.updateStatus()
+}
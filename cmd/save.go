@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+// savedMagic starts every file written with --save. It is followed by a
+// record for each tap event, in the order they were received: the time since
+// the capture started, in nanoseconds, as a uvarint, then the event as a
+// protobuf message prefixed with its length as a uvarint.
+var savedMagic = []byte("tapshark-tap\n")
+
+// An eventSaver writes the raw events of every tap to a --save file. It is
+// shared by the taps, so it may be used from several goroutines.
+type eventSaver struct {
+	mu    sync.Mutex
+	file  *os.File
+	start time.Time
+	// err is the first error writing the file, after which nothing more
+	// is saved.
+	err error
+}
+
+// A savedEvent is a tap event read back from a --save file.
+type savedEvent struct {
+	// offset is when the event was received, since the capture started.
+	offset time.Duration
+	event  *tapPb.TapEvent
+}
+
+// createEventSaver creates the file at path and starts a capture in it.
+func createEventSaver(path string) (*eventSaver, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create --save file: %w", err)
+	}
+	if _, err := file.Write(savedMagic); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write --save file: %w", err)
+	}
+	return &eventSaver{file: file, start: time.Now()}, nil
+}
+
+// save appends event to the file. Each record is written in a single call, so
+// that a capture cut short by a crash is only missing whole events.
+func (s *eventSaver) save(event *tapPb.TapEvent) {
+	msg, err := proto.Marshal(event)
+	if err != nil {
+		log.Debugf("Failed to save tap event: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	record := make([]byte, 0, 2*binary.MaxVarintLen64+len(msg))
+	record = appendUvarint(record, uint64(time.Since(s.start)))
+	record = appendUvarint(record, uint64(len(msg)))
+	record = append(record, msg...)
+	if _, err := s.file.Write(record); err != nil {
+		s.err = err
+		log.Warnf("Failed to write --save file; no more events will be saved: %v", err)
+	}
+}
+
+// tee saves each event from in before passing it on to out, until ctx is
+// done.
+func (s *eventSaver) tee(ctx context.Context, in <-chan *tapPb.TapEvent, out chan<- *tapPb.TapEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-in:
+			s.save(event)
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *eventSaver) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// readSavedEvents reads the events saved at path by --save, which may have
+// been gzipped since.
+func readSavedEvents(path string) ([]savedEvent, error) {
+	file, err := openCapture(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if magic, err := reader.Peek(len(savedMagic)); err != nil || !bytes.Equal(magic, savedMagic) {
+		return nil, fmt.Errorf("%s wasn't written with --save", path)
+	}
+	reader.Discard(len(savedMagic))
+
+	var events []savedEvent
+	for {
+		offset, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err == nil {
+			var event *tapPb.TapEvent
+			event, err = readSavedEvent(reader)
+			events = append(events, savedEvent{offset: time.Duration(offset), event: event})
+		}
+		if err != nil {
+			// A capture that was cut short ends in a partial record.
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				log.Warnf("%s ends in an incomplete event, which was skipped", path)
+				return events, nil
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+}
+
+func readSavedEvent(reader *bufio.Reader) (*tapPb.TapEvent, error) {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(reader, msg); err != nil {
+		return nil, err
+	}
+	event := &tapPb.TapEvent{}
+	if err := proto.Unmarshal(msg, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// replayEvents feeds saved events through pkg.ProcessEvents as if they were
+// being received from a tap, each as long after the event log started as it
+// was received after the capture started, so that the requests are shown with
+// the same timestamps and latencies as they were live. It returns when ctx or
+// done is.
+func (el *eventLog) replayEvents(ctx context.Context, events []savedEvent, done <-chan struct{}) {
+	defer el.recoverPanic()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	eventCh := make(chan *tapPb.TapEvent)
+	requestCh := make(chan pkg.Stream, 100)
+	inProgress := make(chan pkg.Stream, 100)
+	go func() {
+		defer el.recoverPanic()
+		pkg.ProcessEvents(ctx, eventCh, pkg.ProgressChanSink{Done: requestCh, InProgress: inProgress, Stop: ctx.Done()})
+	}()
+	el.goUpdating(func() {
+		defer el.recoverPanic()
+		for _, saved := range events {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(el.start.Add(saved.offset))):
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case eventCh <- saved.event:
+			}
+		}
+		el.app.QueueUpdateDraw(func() {
+			el.notice = fmt.Sprintf("replayed %d tap events", len(events))
+			el.updateStatus()
+		})
+	})
+
+	// A replay is never closed, the way a tap stream is.
+	el.consumeTap(ctx, requestCh, nil, inProgress, done)
+}
+
+// newCmdReplay creates the replay subcommand, which shows a capture saved with
+// --save. It takes the same flags as root, so that the capture can be
+// filtered and shown in the same ways as a live tap.
+func newCmdReplay(root *cobra.Command, options *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay [flags] PATH",
+		Short: "Replay a tap saved with --save",
+		Long: `Replay a tap saved with --save.
+
+  The saved tap events are shown in the interactive UI as they were received,
+  with their original order and timing, without connecting to Kubernetes.`,
+		Example: `  # save a tap of the web deployment, then replay it
+  linkerd tapshark deploy/web --save web.tap
+  linkerd tapshark replay web.tap`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.replayFile = args[0]
+			return root.RunE(cmd, nil)
+		},
+	}
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showSearch asks for text to find in the focused table, then selects the
+// next request that contains it.
+func (el *eventLog) showSearch() {
+	input := tview.NewInputField().SetLabel("Find: ").SetText(el.search)
+	input.SetBorder(true).SetTitle(" Search ")
+	// The overlay takes the focus, which the table being searched gets back
+	// once it closes.
+	focused := el.app.GetFocus()
+	input.SetDoneFunc(func(key tcell.Key) {
+		el.closeOverlay()
+		el.app.SetFocus(focused)
+		if key == tcell.KeyEnter && input.GetText() != "" {
+			el.search = input.GetText()
+			el.searchNext()
+		}
+	})
+	el.showOverlay(input)
+}
+
+// searchNext selects the first request after the selected one, wrapping
+// around to the top, with a column that contains the last text searched for,
+// ignoring case.
+func (el *eventLog) searchNext() {
+	if el.search == "" || el.summary != nil {
+		return
+	}
+	table, rows := el.table, el.rows
+	if el.split && el.outbound.HasFocus() {
+		table, rows = el.outbound, el.outboundRows
+	}
+	text := strings.ToLower(el.search)
+	selected, _ := table.GetSelection()
+	for i := 1; i <= len(rows); i++ {
+		// Rows are numbered from 1, after the header.
+		row := (selected+i-1)%len(rows) + 1
+		if r := rows[row-1]; r.marker == nil && el.matchesSearch(r.event, text) {
+			table.Select(row, 0)
+			el.showDetails(rows, row)
+			return
+		}
+	}
+	el.notice = fmt.Sprintf("no requests match %q", el.search)
+	el.updateStatus()
+}
+
+// matchesSearch reports whether any column shows text for events[idx].
+func (el *eventLog) matchesSearch(idx int, text string) bool {
+	req := el.events[idx]
+	for _, col := range el.columns {
+		if strings.Contains(strings.ToLower(col.value(el, req)), text) {
+			return true
+		}
+	}
+	return false
+}
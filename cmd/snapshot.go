@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// writeSnapshot saves the text currently on screen, without colors or other
+// formatting, to a file in the working directory and reports where it went in
+// the status line.
+func (el *eventLog) writeSnapshot() {
+	if el.screen == nil {
+		return
+	}
+	path := fmt.Sprintf("tapshark-%s.txt", time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(path, []byte(screenText(el.screen)), 0644); err != nil {
+		el.notice = fmt.Sprintf("snapshot failed: %v", err)
+	} else {
+		el.notice = fmt.Sprintf("snapshot written to %s", path)
+	}
+	el.updateStatus()
+}
+
+// screenText reads back every cell of the screen as plain text.
+func screenText(screen tcell.Screen) string {
+	var b strings.Builder
+	width, height := screen.Size()
+	for y := 0; y < height; y++ {
+		var line strings.Builder
+		for x := 0; x < width; {
+			mainc, combc, _, w := screen.GetContent(x, y)
+			if mainc == 0 {
+				mainc = ' '
+			}
+			line.WriteRune(mainc)
+			for _, c := range combc {
+				line.WriteRune(c)
+			}
+			if w < 1 {
+				w = 1
+			}
+			x += w
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMaxRps is the rate limit the tap server applies when --max-rps
+	// is not set.
+	defaultMaxRps = 100.0
+
+	// samplingThreshold is the fraction of the rate limit above which we
+	// assume the proxies are dropping requests rather than reporting them.
+	samplingThreshold = 0.9
+)
+
+// updateStatus redraws the status line from the current state.
+func (el *eventLog) updateStatus() {
+	now := time.Now()
+	parts := []string{
+		now.In(el.location).Format("15:04:05"),
+		fmt.Sprintf("running %s", now.Sub(el.start).Round(time.Second)),
+		fmt.Sprintf("%d requests", len(el.events)),
+		fmt.Sprintf("%d sources", len(el.sources)),
+		fmt.Sprintf("%d destinations", len(el.destinations)),
+	}
+	if tap := el.tapStatus(now); tap != "" {
+		parts = append([]string{tap}, parts...)
+	}
+	if el.warning != "" {
+		parts = append(parts, fmt.Sprintf("[black:red] WARNING [-:-] %s", el.warning))
+	}
+	if el.edgeName != "" {
+		parts = append(parts, fmt.Sprintf("[black:blue] EDGE [-:-] only %s", el.edgeName)+el.keyHint("edge", "show all"))
+	}
+	if filters := el.filterSummary(); filters != "" {
+		parts = append(parts, filters)
+	}
+	if el.notice != "" {
+		parts = append(parts, el.notice)
+	}
+	if el.sampleRate < 1 {
+		parts = append(parts, fmt.Sprintf("[black:yellow] SAMPLING [-:-] showing %g%% of requests", 100*el.sampleRate))
+	}
+	if el.sampled > 0 {
+		parts = append(parts, fmt.Sprintf("[black:yellow] SAMPLED [-:-] traffic reached the --max-rps limit of %g; not every request is shown", el.maxRps))
+	}
+	el.status.SetText(strings.Join(parts, "  "))
+}
+
+// tapStatus describes the state of the tap streams, so that a quiet service
+// can be told apart from a broken tap. The dot blinks while the taps are
+// streaming. It is empty when nothing is being tapped.
+func (el *eventLog) tapStatus(now time.Time) string {
+	switch {
+	case el.session == nil:
+		return ""
+	case el.tapsConnected > 0:
+		dot := "●"
+		if now.Second()%2 == 1 {
+			dot = "○"
+		}
+		if len(el.events) == 0 {
+			return fmt.Sprintf("[green]%s[-] connected, waiting for traffic", dot)
+		}
+		return fmt.Sprintf("[green]%s[-] live", dot)
+	case el.tapsConnecting > 0:
+		return "[yellow]○[-] connecting"
+	case el.tapError != "":
+		return fmt.Sprintf("[red]○ disconnected[-] %s", el.tapError)
+	default:
+		return "[red]○ disconnected[-]"
+	}
+}
+
+// tickClock keeps the clock in the status line current until done is closed.
+func (el *eventLog) tickClock(done <-chan struct{}) {
+	defer el.recoverPanic()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			el.app.QueueUpdateDraw(func() {
+				el.updateStatus()
+				// How long each stream has been open changes
+				// every second.
+				el.refreshOpenStreams()
+			})
+		}
+	}
+}
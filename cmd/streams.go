@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/rivo/tview"
+)
+
+// An openStream is a request that has started but not yet ended, such as a
+// long-lived gRPC stream.
+type openStream struct {
+	req pkg.Stream
+	// started is when its request was reported. The tap doesn't say when
+	// a request started, only how long after it the response did.
+	started time.Time
+}
+
+// progressStream records that req, which hasn't ended, was reported at now.
+func (el *eventLog) progressStream(req pkg.Stream, now time.Time) {
+	id := req.ID()
+	if stream, ok := el.open[id]; ok {
+		stream.req = req
+	} else {
+		el.open[id] = &openStream{req: req, started: now}
+	}
+	el.refreshOpenStreams()
+}
+
+// endStream forgets the stream with the given id, which has ended or will
+// never be reported as ending.
+func (el *eventLog) endStream(id pkg.StreamID) {
+	if _, ok := el.open[id]; !ok {
+		return
+	}
+	delete(el.open, id)
+	el.refreshOpenStreams()
+}
+
+func (el *eventLog) refreshOpenStreams() {
+	if el.summary == el.openStreams {
+		el.openStreams.render(el.openStreams.table, el.events)
+	}
+}
+
+// renderOpenStreams shows every request that hasn't ended yet, oldest first,
+// with how long it waited for response headers and how long it has been open.
+// It ignores events, which have all ended.
+func (el *eventLog) renderOpenStreams(table *tview.Table, _ []pkg.Stream) {
+	streams := make([]*openStream, 0, len(el.open))
+	for _, stream := range el.open {
+		streams = append(streams, stream)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].started.Before(streams[j].started) })
+
+	now := time.Now()
+	setHeader(table, "STARTED", pad("FROM"), pad("POD"), pad("TO"), pad("PATH"), pad("PHASE"), pad("HEADERS AFTER"), "OPEN FOR")
+	for i, stream := range streams {
+		req := stream.req
+		from, pod, to := el.fromPodTo(req)
+		phase := "awaiting response"
+		var headersAfter string
+		if req.RspInit != nil {
+			phase = fmt.Sprintf("streaming %d response", req.RspInit.GetHttpStatus())
+			if d, err := ptypes.Duration(req.RspInit.GetSinceRequestInit()); err == nil {
+				headersAfter = d.String()
+			}
+		}
+		sinceStart := stream.started.Sub(el.start).Milliseconds()
+		table.SetCellSimple(i+1, 0, el.formatTime(uint64(sinceStart), stream.started))
+		table.SetCellSimple(i+1, 1, pad(from))
+		table.SetCellSimple(i+1, 2, pad(pod))
+		table.SetCellSimple(i+1, 3, pad(to))
+		table.SetCellSimple(i+1, 4, pad(req.ReqInit.GetPath()))
+		table.SetCellSimple(i+1, 5, pad(phase))
+		table.SetCellSimple(i+1, 6, pad(headersAfter))
+		table.SetCellSimple(i+1, 7, now.Sub(stream.started).Round(time.Second).String())
+	}
+	truncateRows(table, len(streams)+1)
+}
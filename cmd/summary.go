@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// printSummary writes a short report of the capture to stdout once the UI has
+// exited.
+func (el *eventLog) printSummary() {
+	elapsed := time.Since(el.start).Round(time.Millisecond)
+	fmt.Printf("Captured %d requests in %s\n", len(el.events), elapsed)
+
+	// The tap API only reports the size of response bodies; request bodies are
+	// not accounted for.
+	var responseBytes uint64
+	for _, req := range el.events {
+		responseBytes += req.RspEnd.GetResponseBytes()
+	}
+	var throughput float64
+	if elapsed > 0 {
+		throughput = float64(responseBytes) / elapsed.Seconds()
+	}
+	fmt.Printf("Response bytes: %d (%.1f bytes/sec)\n", responseBytes, throughput)
+}
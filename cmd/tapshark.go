@@ -2,34 +2,236 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
 	"os"
+	"os/signal"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/adleong/tapshark/pkg"
 	"github.com/gdamore/tcell/v2"
 	"github.com/golang/protobuf/ptypes"
+	netPb "github.com/linkerd/linkerd2/controller/gen/common/net"
 	"github.com/linkerd/linkerd2/pkg/addr"
 	pkgcmd "github.com/linkerd/linkerd2/pkg/cmd"
 	"github.com/linkerd/linkerd2/pkg/healthcheck"
 	"github.com/linkerd/linkerd2/pkg/k8s"
-	"github.com/linkerd/linkerd2/viz/pkg/util"
 	"github.com/linkerd/linkerd2/viz/pkg/api"
+	"github.com/linkerd/linkerd2/viz/pkg/util"
 	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
 	tapPkg "github.com/linkerd/linkerd2/viz/tap/pkg"
 	"github.com/rivo/tview"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-const	defaultLinkerdNamespace = "linkerd"
+const (
+	defaultLinkerdNamespace = "linkerd"
+	defaultKubeTimeout      = 30 * time.Second
+
+	// sideBySideWidth is the minimum terminal width at which request and
+	// response details are shown next to each other.
+	sideBySideWidth = 120
+
+	// The details pane can be resized between these fractions of the
+	// height, a step at a time.
+	minDetailRatio  = 0.1
+	maxDetailRatio  = 0.9
+	detailRatioStep = 0.1
+)
 
 type (
 	eventLog struct {
-		app     *tview.Application
-		table   *tview.Table
-		details *tview.TextView
+		app      *tview.Application
+		root     *tview.Flex
+		grid     *tview.Grid
+		table    *tview.Table
+		outbound *tview.Table
+		details  *tview.Grid
+		done     chan struct{}
+
+		// requestDetails and responseDetails make up the details pane, which
+		// shows events[detailEvent], or nothing if that is -1.
+		requestDetails  *tview.TextView
+		responseDetails *tview.TextView
+		detailEvent     int
+		status          *tview.TextView
+
 		events  []pkg.Stream
+		markers []marker
+		// sources and destinations are the distinct peers seen.
+		sources      map[string]struct{}
+		destinations map[string]struct{}
+
+		start   time.Time
+		limit   int
+		filters []filter
+		maxRps  float32
+		// sampled is the number of taps whose last one second window came
+		// close to maxRps.
+		sampled int
+		// sampleRate is the fraction of requests shown; see sample.
+		sampleRate float64
+		// warning, if set, is a problem found before the tap started.
+		warning string
+		// highlights are applied to the rows of the requests they match,
+		// in order.
+		highlights []highlight
+		// edgeFilter, if set, hides every request that isn't on the
+		// edge named by edgeName.
+		edgeFilter filter
+		edgeName   string
+		// search is the text last searched for in the table.
+		search string
+		// clientFilters describes filters, for the filters view.
+		clientFilters []activeFilter
+		// notice, if set, is the result of the last action taken.
+		notice string
+		// otel, if set, exports every accepted request as a span.
+		otel *otelExporter
+		// title is the command line shown in the grid's title; see
+		// updateTitle.
+		title string
+		// paused is set while the table is frozen. It is only set on the
+		// UI goroutine, under pauseMu, which the taps take to read it.
+		pauseMu sync.Mutex
+		paused  bool
+		// held is how many of the requests at the end of events
+		// completed while the view was paused, and are not in the table
+		// yet.
+		held int
+		// running counts the goroutines that update the UI until done is
+		// closed, which quit waits for; see goUpdating. stopOnce closes
+		// done.
+		running  sync.WaitGroup
+		stopOnce sync.Once
+		// saver, if set, saves every event the taps receive.
+		saver *eventSaver
+		// session is the running tap, if any. editing is set while the
+		// form for changing its filters is open.
+		session *tapSession
+		editing bool
+		recent  *recentIDs
+		// captured counts the requests accepted by every tap, for --limit.
+		captured int64
+		// seq is the sequence number of the last request added.
+		seq uint64
+		// lastActivity is when any tap last reported a request, in Unix
+		// nanoseconds. With idleTimeout set, the UI is stopped once it is
+		// that long ago.
+		lastActivity int64
+		idleTimeout  time.Duration
+		// summaryFile, if set, is where the aggregates are written on
+		// exit.
+		summaryFile string
+		// tapsConnecting and tapsConnected count the taps that are waiting
+		// for the tap server to respond and those that are streaming.
+		// tapError is why the last tap that failed to start did.
+		tapsConnecting int
+		tapsConnected  int
+		tapError       string
+		// screen is the screen the UI was last drawn to, for snapshots.
+		screen tcell.Screen
+
+		// fullAddress shows peers as ip:port rather than by pod name.
+		fullAddress bool
+		theme       theme
+		// noColor shows rows in the usual text color rather than by
+		// status; see rowColor.
+		noColor bool
+		// anonymizer, if set, hides pod, CronJob and Job names and IP addresses.
+		anonymizer *anonymizer
+		// detailRatio is the fraction of the height given to the details
+		// pane, or 0 to size it the same as each table.
+		detailRatio float64
+		// absoluteTime shows the wall clock time of each request rather than
+		// the time since the capture started, in location.
+		absoluteTime bool
+		location     *time.Location
+
+		// rows and outboundRows hold each row (after the header) of table and
+		// outbound in display order. outboundRows is only populated when split
+		// is set.
+		rows         []tableRow
+		outboundRows []tableRow
+		columns      []column
+		// detailFields are the fields shown in the details pane, in order.
+		detailFields []detailField
+		// maxLatency is the slowest request seen, which latency bars are
+		// scaled to.
+		maxLatency time.Duration
+		sortColumn int // An index into columns, or -1 for arrival order
+		sortDesc   bool
+		// newestFirst puts new requests at the top of the table rather
+		// than the bottom while it is in arrival order.
+		newestFirst bool
+		split       bool
+		// selectLatest keeps the newest request selected and its details
+		// shown.
+		selectLatest bool
+		// responsive hides the columns in columnHidePriority, least
+		// important first, when the terminal is too narrow for them.
+		// columnWidths holds the widest text in each column.
+		responsive    bool
+		columnWidths  map[string]int
+		hiddenColumns map[string]bool
+		// dedupWindow, if set, collapses identical requests that arrive
+		// within this long of each other into one row.
+		dedupWindow time.Duration
+		// repeats holds, for each event, the number of later requests
+		// collapsed into its row, or -1 if it was itself collapsed.
+		repeats   []int
+		collapsed map[string]*collapsedRow
+
+		// summary, if set, is shown in place of the request tables.
+		summary     *summaryView
+		statusCodes *summaryView
+		routes      *summaryView
+		latencies   *summaryView
+		connections *summaryView
+		heatmap     *summaryView
+		openStreams *summaryView
+		filterList  *summaryView
+		// tracesGrouped shows correlated requests together; see
+		// groupTraces. foldedTraces holds the traces whose later requests
+		// are hidden.
+		tracesGrouped bool
+		foldedTraces  map[string]bool
+		// latencyUnit, if set, is the unit latencies are shown in; see
+		// formatDuration.
+		latencyUnit string
+		// openTemplate is the URL opened for a request; see
+		// expandOpenTemplate.
+		openTemplate string
+		// keymap binds key names, as given by keyName, to the names of
+		// keyActions.
+		keymap map[string]string
+		// open holds the requests that have started but not yet ended.
+		open map[pkg.StreamID]*openStream
+	}
+
+	// A tableRow is either a request, given as an index into events, or a
+	// marker.
+	tableRow struct {
+		event  int
+		marker *marker
+		// traceChild rows follow the first request of their trace when
+		// requests are grouped by trace, and traceSize counts the rows of
+		// the trace that starts with this one.
+		traceChild bool
+		traceSize  int
 	}
 
 	options struct {
@@ -39,6 +241,7 @@ type (
 		kubeContext           string
 		impersonate           string
 		impersonateGroup      []string
+		kubeTimeout           time.Duration
 
 		namespace     string
 		toResource    string
@@ -49,12 +252,78 @@ type (
 		authority     string
 		path          string
 		labelSelector string
+		limit         int
+		quiet         bool
+		tlsOnly       bool
+		plaintextOnly bool
+		fromJSONFile  string
+		fullAddress   bool
+		theme         string
+		pollK8sEvents bool
+		otelEndpoint  string
+		anonymize     bool
+		selectFirst   bool
+		columns       []string
+		detailFields  []string
+		countBy       string
+		noTUI         bool
+		output        string
+		summaryFile   string
+		statsInterval time.Duration
+		dedupWindow   time.Duration
+		detailRatio   float64
+		responsive    bool
+		sampleRate    float64
+		order         string
+		timeZone      string
+		replayTo      string
+		idleTimeout   time.Duration
+		replaySpeed   float64
+		container     string
+		grpcStatus    string
+		grpcErrors    bool
+		hasHeaders    []string
+		highlights    []string
+		zone          string
+		crossZoneOnly bool
+		notHeaders    []string
+		noColor       bool
+
+		namespaceSelector string
+		nameRegex         string
+		pprofAddr         string
+		keymap            string
+		htmlFile          string
+		latencyUnit       string
+		openTemplate      string
+		save              string
+
+		waitForControlPlane time.Duration
+
+		// location is parsed from timeZone.
+		location *time.Location
+		// keys is parsed from keymap.
+		keys map[string]string
+		// saver is opened from save.
+		saver *eventSaver
+		// otel is created from otelEndpoint.
+		otel *otelExporter
+		// replayFile is the file given to the replay subcommand.
+		replayFile string
 	}
 )
 
 // NewCmdTapShark creates a new cobra command `tap` for tap functionality
 func NewCmdTapShark() *cobra.Command {
-	options := options{}
+	options := options{
+		sampleRate:  1,
+		order:       "oldest-first",
+		timeZone:    "Local",
+		replaySpeed: 1,
+		output:      "json",
+
+		openTemplate: defaultOpenTemplate,
+	}
 
 	cmd := &cobra.Command{
 		Use:   "tapshark [flags] (RESOURCE)",
@@ -87,30 +356,229 @@ func NewCmdTapShark() *cobra.Command {
   * replicasets
   * replicationcontrollers
   * statefulsets
-  * services (only supported as a --to resource)`,
+  * services (only supported as a --to resource)
+
+  RESOURCE may also be the IP address of a meshed pod, optionally with a
+  port, such as 10.42.0.15 or 10.42.0.15:8080. It is tapped in whichever
+  namespace the pod is in.`,
 		Example: `  # tap the web deployment in the default namespace
   linkerd tapshark deploy/web
 
   # tap the web-dlbvj pod in the default namespace
   linkerd tapshark pod/web-dlbvj
 
+  # tap whichever pod has the IP address 10.42.0.15
+  linkerd tapshark 10.42.0.15
+
   # tap the test namespace, filter by request to prod namespace
   linkerd tapshark ns/test --to ns/prod`,
-		Args:      cobra.RangeArgs(1, 2),
+		Args:      cobra.RangeArgs(0, 2),
 		ValidArgs: util.ValidTargets,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.limit < 0 {
+				return fmt.Errorf("--limit must be non-negative")
+			}
+			if options.sampleRate <= 0 || options.sampleRate > 1 {
+				return fmt.Errorf("--sample-rate must be greater than 0 and at most 1")
+			}
+			rand.Seed(time.Now().UnixNano())
+			if options.replayTo != "" && options.fromJSONFile == "" {
+				return errors.New("--replay-to requires --from-json-file")
+			}
+			if options.htmlFile != "" && options.fromJSONFile == "" {
+				return errors.New("--html-file requires --from-json-file")
+			}
+			if options.waitForControlPlane < 0 {
+				return errors.New("--wait-for-control-plane must be non-negative")
+			}
+			if options.replaySpeed <= 0 {
+				return errors.New("--replay-speed must be greater than 0")
+			}
+			// Choosing an output format implies writing requests to
+			// stdout rather than showing the UI.
+			if cmd.Flags().Changed("output") {
+				if options.fromJSONFile != "" {
+					return errors.New("--output can't be used with --from-json-file")
+				}
+				options.noTUI = true
+			}
+			if options.save != "" && options.fromJSONFile != "" {
+				return errors.New("--save can't be used with --from-json-file")
+			}
+			// --save writes the raw tap events, which --anonymize
+			// doesn't rewrite.
+			if options.save != "" && options.anonymize {
+				return errors.New("--save can't be used with --anonymize")
+			}
+			if options.replayFile != "" && (options.fromJSONFile != "" || options.noTUI || options.save != "") {
+				return errors.New("replay can't be used with --from-json-file, --no-tui, --output or --save")
+			}
+			// Spans are only exported for requests as they are tapped, not
+			// for ones read back from a file.
+			if options.otelEndpoint != "" && (options.fromJSONFile != "" || options.replayFile != "") {
+				return errors.New("--otel-endpoint can't be used with --from-json-file or replay")
+			}
+			if options.summaryFile != "" && options.noTUI {
+				return errors.New("--summary-file can't be used with --no-tui")
+			}
+			if options.output != "json" && options.output != "binary" && options.output != "csv" {
+				return errors.New("--output must be json, binary or csv")
+			}
+			if _, ok := latencyUnits[options.latencyUnit]; options.latencyUnit != "" && !ok {
+				return errors.New("--latency-unit must be us, ms or s")
+			}
+			if err := validateOpenTemplate(options.openTemplate); err != nil {
+				return err
+			}
+			if options.order != "oldest-first" && options.order != "newest-first" {
+				return fmt.Errorf("--order must be newest-first or oldest-first")
+			}
+			if options.detailRatio != 0 && (options.detailRatio < minDetailRatio || options.detailRatio > maxDetailRatio) {
+				return fmt.Errorf("--detail-ratio must be between %.1f and %.1f", minDetailRatio, maxDetailRatio)
+			}
+
+			location, err := time.LoadLocation(options.timeZone)
+			if err != nil {
+				return fmt.Errorf("invalid --time-zone: %w", err)
+			}
+			options.location = location
+
+			options.keys, err = loadKeymap(options.keymap)
+			if err != nil {
+				return err
+			}
+
+			var nameRegex *regexp.Regexp
+			if options.nameRegex != "" {
+				nameRegex, err = regexp.Compile(options.nameRegex)
+				if err != nil {
+					return fmt.Errorf("invalid --name-regex: %w", err)
+				}
+				if len(args) != 1 {
+					return errors.New("--name-regex needs a single RESOURCE type, such as deploy")
+				}
+			}
+
+			filters, err := buildFilters(&options)
+			if err != nil {
+				return err
+			}
+
+			highlights, err := parseHighlights(options.highlights)
+			if err != nil {
+				return err
+			}
+
+			columns, err := selectColumns(options.columns)
+			if err != nil {
+				return err
+			}
+			detailFields, err := selectDetailFields(options.detailFields)
+			if err != nil {
+				return err
+			}
+
+			var countBy *column
+			if options.countBy != "" {
+				col, err := countByColumn(options.countBy)
+				if err != nil {
+					return err
+				}
+				countBy = &col
+			}
+
+			theme, err := lookupTheme(options.theme)
+			if err != nil {
+				return err
+			}
+			// Primitives take their colors from the global styles when
+			// they are created.
+			tview.Styles = theme.Theme
+
+			if options.quiet {
+				log.SetLevel(log.ErrorLevel)
+			}
+
+			// The flags are valid, so any error from here on isn't helped
+			// by printing the usage.
+			cmd.SilenceUsage = true
+
+			if options.pprofAddr != "" {
+				servePprof(options.pprofAddr)
+			}
+
+			// On SIGINT or SIGTERM the taps are closed and the UI stopped,
+			// so that the summary is printed and any exports are flushed
+			// before exiting.
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if options.fromJSONFile != "" {
+				events, metadata, err := readCapture(options.fromJSONFile)
+				if err != nil {
+					return err
+				}
+				if options.replayTo != "" {
+					var accepted []pkg.Stream
+					for _, req := range events {
+						if acceptAll(filters, req) && sample(options.sampleRate) {
+							accepted = append(accepted, req)
+						}
+					}
+					return replayRequests(ctx, accepted, options.replayTo, options.replaySpeed)
+				}
+				eventLog := newEventLog(&options, filters, highlights, theme, columns, detailFields, countBy)
+				for _, req := range events {
+					if eventLog.accept(req) && sample(eventLog.sampleRate) {
+						eventLog.addEvent(req)
+					}
+				}
+				if metadata != nil {
+					eventLog.showCapture(metadata)
+				}
+				if options.htmlFile != "" {
+					return eventLog.writeHTMLFile(options.htmlFile)
+				}
+				return eventLog.run(ctx)
+			}
+
+			if options.replayFile != "" {
+				events, err := readSavedEvents(options.replayFile)
+				if err != nil {
+					return err
+				}
+				eventLog := newEventLog(&options, filters, highlights, theme, columns, detailFields, countBy)
+				eventLog.goUpdating(func() { eventLog.replayEvents(ctx, events, eventLog.done) })
+				return eventLog.run(ctx)
+			}
+
+			if len(args) == 0 && options.namespaceSelector == "" {
+				return errors.New("a RESOURCE to tap is required")
+			}
+
 			if options.namespace == "" {
 				options.namespace = pkgcmd.GetDefaultNamespace(options.kubeconfigPath, options.kubeContext)
 			}
 
-			api.CheckClientOrExit(healthcheck.Options{
-				ControlPlaneNamespace: options.controlPlaneNamespace,
-				KubeConfig:            options.kubeconfigPath,
-				Impersonate:           options.impersonate,
-				ImpersonateGroup:      options.impersonateGroup,
-				KubeContext:           options.kubeContext,
-				APIAddr:               options.apiAddr,
-			})
+			// The health checks connect with the kubeconfig, which may not
+			// be usable when the API is reached through a socket.
+			if _, ok := unixSocketPath(options.apiAddr); !ok {
+				hcOptions := healthcheck.Options{
+					ControlPlaneNamespace: options.controlPlaneNamespace,
+					KubeConfig:            options.kubeconfigPath,
+					Impersonate:           options.impersonate,
+					ImpersonateGroup:      options.impersonateGroup,
+					KubeContext:           options.kubeContext,
+					APIAddr:               options.apiAddr,
+				}
+				if options.waitForControlPlane > 0 {
+					if err := waitForControlPlane(ctx, hcOptions, options.waitForControlPlane); err != nil {
+						return err
+					}
+				} else {
+					api.CheckClientOrExit(hcOptions)
+				}
+			}
 
 			requestParams := tapPkg.TapRequestParams{
 				Resource:      strings.Join(args, "/"),
@@ -126,69 +594,123 @@ func NewCmdTapShark() *cobra.Command {
 				LabelSelector: options.labelSelector,
 			}
 
-			req, err := tapPkg.BuildTapByResourceRequest(requestParams)
+			k8sAPI, err := newKubernetesAPI(&options)
 			if err != nil {
 				fmt.Fprint(os.Stderr, err.Error())
 				os.Exit(1)
 			}
 
-			k8sAPI, err := k8s.NewAPI(options.kubeconfigPath, options.kubeContext, options.impersonate, options.impersonateGroup, 0)
-			if err != nil {
-				fmt.Fprint(os.Stderr, err.Error())
-				os.Exit(1)
+			if ip, ok := parseTargetIP(requestParams.Resource); ok {
+				namespace, resource, err := resolvePodIP(ctx, k8sAPI, ip, options.controlPlaneNamespace)
+				if err != nil {
+					return err
+				}
+				log.Infof("Tapping %s/%s, which has the IP address %s", namespace, resource, ip)
+				options.namespace = namespace
+				requestParams.Namespace = namespace
+				requestParams.Resource = resource
 			}
 
-			headers := []string{"TIME", pad("FROM"), pad("POD"), pad("TO"), pad("VERB"), pad("PATH"), pad("STATUS"), "LATENCY"}
-
-			table := tview.NewTable().SetFixed(1, 0).SetSelectable(true, false)
-			for i, header := range headers {
-				cell := tview.NewTableCell(header)
-				cell.SetAttributes(tcell.AttrBold)
-				table.SetCell(0, i, cell)
+			targets := []tapPkg.TapRequestParams{requestParams}
+			var warning string
+			if options.namespaceSelector != "" {
+				namespaces, err := selectNamespaces(ctx, k8sAPI, options.namespaceSelector)
+				if err != nil {
+					return err
+				}
+				if len(namespaces) == 0 {
+					return fmt.Errorf("no namespaces match --namespace-selector %s", options.namespaceSelector)
+				}
+				targets = namespaceTargets(requestParams, namespaces)
+			}
+			if nameRegex != nil {
+				targets, err = nameTargets(ctx, k8sAPI, targets, nameRegex)
+				if err != nil {
+					return err
+				}
+				log.Infof("Tapping %d resources matching --name-regex %s", len(targets), nameRegex)
+			} else if options.namespaceSelector == "" {
+				warning, err = checkMeshed(ctx, k8sAPI, &options, requestParams.Resource)
+				if err != nil {
+					log.Debugf("Failed to check whether the target is meshed: %v", err)
+				} else if warning != "" {
+					log.Warn(warning)
+				}
 			}
 
-			done := make(chan struct{})
-
-			details := tview.NewTextView().SetDynamicColors(true)
-
-			grid := tview.NewGrid().SetSize(2, 1, -1, -1).
-				AddItem(table, 0, 0, 1, 1, 0, 0, true).
-				AddItem(details, 1, 0, 1, 1, 0, 0, false).
-				SetBorders(true)
-			grid.SetTitle(strings.Join(os.Args, " "))
-
-			app := tview.NewApplication().SetRoot(grid, true)
-			app.SetInputCapture(
-				func(event *tcell.EventKey) *tcell.EventKey {
-					if event.Key() == tcell.KeyTAB {
-						if table.HasFocus() {
-							app.SetFocus(details)
-						} else {
-							app.SetFocus(table)
-						}
-						return nil
-					}
-					return event
-				})
+			if version, err := tapServerVersion(ctx, k8sAPI); err != nil {
+				log.Debugf("Failed to find the tap server's version: %v", err)
+			} else if compat := tapCompatibility(version); compat != "" {
+				log.Warn(compat)
+				if warning != "" {
+					warning += "; "
+				}
+				warning += compat
+			}
 
-			eventLog := &eventLog{
-				app:     app,
-				details: details,
-				table:   table,
-				events:  []pkg.Stream{},
+			if options.container != "" {
+				if options.namespaceSelector != "" {
+					return errors.New("--container can't be used with --namespace-selector")
+				}
+				if nameRegex != nil {
+					return errors.New("--container can't be used with --name-regex")
+				}
+				ports, err := containerPorts(ctx, k8sAPI, options.namespace, requestParams.Resource, options.labelSelector, options.container)
+				if err != nil {
+					return err
+				}
+				filters = append(filters, containerFilter(ports))
 			}
 
-			table.SetSelectedFunc(eventLog.selectionChanged)
+			var reqs []*tapPb.TapByResourceRequest
+			for _, target := range targets {
+				req, err := tapPkg.BuildTapByResourceRequest(target)
+				if err != nil {
+					fmt.Fprint(os.Stderr, err.Error())
+					os.Exit(1)
+				}
+				reqs = append(reqs, req)
+			}
 
-			go eventLog.processTapEvents(cmd.Context(), k8sAPI, req, done)
+			if options.save != "" {
+				options.saver, err = createEventSaver(options.save)
+				if err != nil {
+					return err
+				}
+				defer options.saver.Close()
+			}
+			if options.otelEndpoint != "" {
+				options.otel, err = newOTelExporter(options.otelEndpoint)
+				if err != nil {
+					return err
+				}
+				// The last spans are sent once the UI or headless
+				// capture has stopped, however it stopped.
+				defer options.otel.shutdown()
+			}
 
-			if err := app.Run(); err != nil {
-				panic(err)
+			if options.noTUI {
+				return runHeadless(ctx, k8sAPI, reqs, &options, filters, newCaptureRecord(&options, targets))
 			}
 
-			done <- struct{}{}
+			if !stdoutIsTerminal() {
+				log.Warn("stdout isn't a terminal; use --output json to write requests to it as JSON lines instead of showing the interactive UI")
+			}
 
-			return nil
+			eventLog := newEventLog(&options, filters, highlights, theme, columns, detailFields, countBy)
+			if warning != "" {
+				eventLog.warning = warning
+				eventLog.updateStatus()
+			}
+			if err := eventLog.startSession(ctx, k8sAPI, targets); err != nil {
+				return err
+			}
+			if options.pollK8sEvents {
+				for _, target := range targets {
+					go eventLog.watchPods(ctx, k8sAPI, target.Namespace, target.Resource, options.labelSelector, eventLog.done)
+				}
+			}
+			return eventLog.run(ctx)
 		},
 	}
 
@@ -197,9 +719,16 @@ func NewCmdTapShark() *cobra.Command {
 	cmd.Flags().StringVar(&options.kubeContext, "context", "", "Name of the kubeconfig context to use")
 	cmd.Flags().StringVar(&options.impersonate, "as", "", "Username to impersonate for Kubernetes operations")
 	cmd.Flags().StringArrayVar(&options.impersonateGroup, "as-group", []string{}, "Group to impersonate for Kubernetes operations")
-	cmd.Flags().StringVar(&options.apiAddr, "api-addr", "", "Override kubeconfig and communicate directly with the control plane at host:port (mostly for testing)")
+	cmd.Flags().DurationVar(&options.waitForControlPlane, "wait-for-control-plane", options.waitForControlPlane,
+		"Retry the health checks, backing off between attempts, for up to this long rather than exiting if the control plane isn't ready; 0 means fail on the first attempt")
+	cmd.Flags().DurationVar(&options.kubeTimeout, "kube-timeout", defaultKubeTimeout, "Timeout for Kubernetes API requests made while setting up the tap; 0 means no timeout")
+	cmd.Flags().StringVar(&options.apiAddr, "api-addr", "", "Override kubeconfig and communicate directly with the control plane at host:port (mostly for testing), or with the Kubernetes API through a Unix socket given as unix:///path/to/socket")
 	cmd.Flags().StringVarP(&options.namespace, "namespace", "n", options.namespace,
 		"Namespace of the specified resource")
+	cmd.Flags().StringVar(&options.namespaceSelector, "namespace-selector", options.namespaceSelector,
+		"Tap every namespace matching this label selector; RESOURCE, if given, is tapped in each of them")
+	cmd.Flags().StringVar(&options.nameRegex, "name-regex", options.nameRegex,
+		"Tap every resource of the RESOURCE type, such as deploy, whose name matches this regular expression")
 	cmd.Flags().StringVar(&options.toResource, "to", options.toResource,
 		"Display requests to this resource")
 	cmd.Flags().StringVar(&options.toNamespace, "to-namespace", options.toNamespace,
@@ -216,157 +745,975 @@ func NewCmdTapShark() *cobra.Command {
 		"Display requests with paths that start with this prefix")
 	cmd.Flags().StringVarP(&options.labelSelector, "selector", "l", options.labelSelector,
 		"Selector (label query) to filter on, supports '=', '==', and '!='")
+	cmd.Flags().IntVar(&options.limit, "limit", options.limit,
+		"Exit after this many requests have completed; 0 means no limit")
+	cmd.Flags().BoolVar(&options.quiet, "quiet", options.quiet,
+		"Suppress informational and warning messages; errors are still printed to stderr")
+	cmd.Flags().StringVar(&options.fromJSONFile, "from-json-file", options.fromJSONFile,
+		"Browse requests previously exported with --no-tui, as JSON lines or in the binary format, instead of tapping a resource")
+	cmd.Flags().StringVar(&options.latencyUnit, "latency-unit", options.latencyUnit,
+		"Show every latency in this unit, us, ms or s, with a fixed number of decimals, rather than in whichever unit suits each one")
+	cmd.Flags().StringVar(&options.openTemplate, "open-template", options.openTemplate,
+		"URL that O opens for the selected request, with the placeholders {scheme}, {authority}, {path}, {method}, {status}, {request_id} and {trace_id}")
+	cmd.Flags().StringVar(&options.save, "save", options.save,
+		"Save every tap event received to this file, which tapshark replay plays back in the UI with its original timing")
+	cmd.Flags().StringVar(&options.htmlFile, "html-file", options.htmlFile,
+		"With --from-json-file, write the requests to this file as a standalone HTML page and exit instead of browsing them")
+	cmd.Flags().StringSliceVar(&options.columns, "columns", options.columns,
+		"Comma-separated list of columns to show, in order; by default every column except SCHEME and LATENCY-BAR is shown")
+	cmd.Flags().StringSliceVar(&options.detailFields, "detail-fields", options.detailFields,
+		"Comma-separated list of fields to show in the details pane, in order; by default every field except tls-version and tls-cipher is shown")
+	cmd.Flags().DurationVar(&options.dedupWindow, "dedup-window", options.dedupWindow,
+		"Collapse identical requests (same peers, verb, path, and status) that arrive within this long of the previous one into a single row; 0 shows every request")
+	cmd.Flags().Float64Var(&options.detailRatio, "detail-ratio", options.detailRatio,
+		"Fraction of the screen height given to the details pane, such as 0.7; by default it is the same height as the request table")
+	cmd.Flags().BoolVar(&options.responsive, "responsive", options.responsive,
+		"Hide less important columns, starting with REQ-HDRS and RSP-HDRS, when the terminal is too narrow to show them all")
+	cmd.Flags().Float64Var(&options.sampleRate, "sample-rate", options.sampleRate,
+		"Display only this fraction of requests, chosen at random, such as 0.1 for one in ten; unlike --max-rps, this is applied by tapshark after the requests are reported")
+	cmd.Flags().StringVar(&options.countBy, "count-by", options.countBy,
+		"Show live request counts grouped by this column, such as path, status, pod, or method, instead of individual requests")
+	cmd.Flags().BoolVar(&options.noTUI, "no-tui", options.noTUI,
+		"Write each request to stdout as a line of JSON, or in the --output format, instead of showing the interactive UI")
+	cmd.Flags().StringVar(&options.summaryFile, "summary-file", options.summaryFile,
+		"On exit, write the count, success rate and latency percentiles of each route, path and edge to this file, as JSON if it ends in .json and CSV otherwise")
+	cmd.Flags().StringVar(&options.output, "output", options.output,
+		"Write each request to stdout in this format instead of showing the interactive UI: json for a line of JSON per request, binary for a compact format that --from-json-file also reads, or csv for a row per request with the table's columns")
+	cmd.Flags().StringVar(&options.replayTo, "replay-to", options.replayTo,
+		"Instead of browsing --from-json-file, send its requests to this base URL, such as http://localhost:8080, with their original timing")
+	cmd.Flags().Float64Var(&options.replaySpeed, "replay-speed", options.replaySpeed,
+		"With --replay-to, how many times faster than captured to send the requests")
+	cmd.Flags().DurationVar(&options.idleTimeout, "idle-timeout", options.idleTimeout,
+		"Exit, printing the summary, once no requests have been reported for this long; 0 means never")
+	cmd.Flags().DurationVar(&options.statsInterval, "stats-interval", options.statsInterval,
+		"With --no-tui, print a summary of the capture to stderr this often; 0 disables it")
+	cmd.Flags().StringVar(&options.theme, "theme", defaultTheme,
+		"Color theme: dark, light, high-contrast, or colorblind")
+	cmd.Flags().BoolVar(&options.noColor, "no-color", options.noColor,
+		"Show every row of the table in the usual text color, rather than in the color of its status, with only --highlight rules in color")
+	cmd.Flags().BoolVar(&options.fullAddress, "full-address", options.fullAddress,
+		"Show the full ip:port of peers instead of their pod names")
+	cmd.Flags().BoolVar(&options.anonymize, "anonymize", options.anonymize,
+		"Replace pod, CronJob and Job names and IP addresses with stable pseudonyms, for sharing captures")
+	cmd.Flags().BoolVar(&options.selectFirst, "select-first", options.selectFirst,
+		"Keep the newest request selected so that its details are always shown")
+	cmd.Flags().StringVar(&options.timeZone, "time-zone", options.timeZone,
+		"Time zone of wall clock times, such as UTC or America/New_York")
+	cmd.Flags().StringVar(&options.order, "order", options.order,
+		"Where new requests are added to the table: oldest-first appends them to the bottom, newest-first to the top")
+	cmd.Flags().BoolVar(&options.tlsOnly, "tls-only", options.tlsOnly,
+		"Display only requests over meshed mTLS connections")
+	cmd.Flags().BoolVar(&options.plaintextOnly, "plaintext-only", options.plaintextOnly,
+		"Display only requests over connections without mTLS")
+	cmd.Flags().StringVar(&options.grpcStatus, "grpc-status", options.grpcStatus,
+		"Display only gRPC requests that ended with this status, given by name (such as UNAVAILABLE) or code")
+	cmd.Flags().BoolVar(&options.grpcErrors, "grpc-errors", options.grpcErrors,
+		"Display only gRPC requests that ended with a status other than OK")
+	cmd.Flags().StringArrayVar(&options.hasHeaders, "has-header", options.hasHeaders,
+		"Display only requests whose request or response headers include this header, given as name or name=value; may be repeated")
+	cmd.Flags().StringArrayVar(&options.highlights, "highlight", options.highlights,
+		"Show requests that match this rule in bold and in color, without hiding the rest; for example path=/api,status=5xx,color=red. Conditions are path (a prefix), method, authority, status (a code or class such as 5xx) and header (name or name=value); may be repeated")
+	cmd.Flags().StringArrayVar(&options.notHeaders, "not-header", options.notHeaders,
+		"Display only requests whose request and response headers don't include this header, given as name or name=value; may be repeated")
+	cmd.Flags().StringVar(&options.zone, "zone", options.zone,
+		"Display only requests whose client or server is in this topology zone")
+	cmd.Flags().BoolVar(&options.crossZoneOnly, "cross-zone-only", options.crossZoneOnly,
+		"Display only requests between peers in different topology zones; requests with a peer of unknown zone are hidden")
+	cmd.Flags().StringVar(&options.container, "container", options.container,
+		"Display only inbound requests to this container, matched by the ports it declares; outbound requests can't be attributed to a container and are hidden")
+	cmd.Flags().BoolVar(&options.pollK8sEvents, "poll-k8s-events", options.pollK8sEvents,
+		"Watch the tapped pods and show a row in the table when one is created, restarted, or deleted")
+	cmd.Flags().StringVar(&options.otelEndpoint, "otel-endpoint", options.otelEndpoint,
+		"Export requests as spans to the OTLP/HTTP collector at this URL, such as http://localhost:4318")
+	cmd.Flags().StringVar(&options.keymap, "keymap", options.keymap,
+		"Read key bindings from this file rather than from tapshark/keymap in the user config directory, if it exists")
+	cmd.Flags().StringVar(&options.pprofAddr, "pprof-addr", options.pprofAddr,
+		"Serve Go profiling data for tapshark itself on this address, such as localhost:6060")
+	// MarkHidden only fails if the flag wasn't declared above.
+	if err := cmd.Flags().MarkHidden("pprof-addr"); err != nil {
+		panic(err)
+	}
+
+	cmd.AddCommand(newCmdReplay(cmd, &options))
 
 	return cmd
 }
 
-func (el *eventLog) processTapEvents(ctx context.Context, k8sAPI *k8s.KubernetesAPI, req *tapPb.TapByResourceRequest, done <-chan struct{}) {
-	reader, body, err := tapPkg.Reader(ctx, k8sAPI, req)
+// newEventLog builds the UI. Events are added to it by processTapEvents, or
+// directly before run is called.
+func newEventLog(options *options, filters []filter, highlights []highlight, theme theme, columns []column, detailFields []detailField, countBy *column) *eventLog {
+	table := tview.NewTable().SetFixed(1, 0).SetSelectable(true, false)
+	outbound := tview.NewTable().SetFixed(1, 0).SetSelectable(true, false)
+
+	requestDetails := tview.NewTextView().SetDynamicColors(true)
+	responseDetails := tview.NewTextView().SetDynamicColors(true)
+
+	// The request and response details are stacked on narrow terminals
+	// and side by side on wide ones.
+	details := tview.NewGrid().SetRows(-1, -1).SetColumns(-1, -1).SetGap(0, 2).
+		AddItem(requestDetails, 0, 0, 1, 2, 0, 0, false).
+		AddItem(responseDetails, 1, 0, 1, 2, 0, 0, false).
+		AddItem(requestDetails, 0, 0, 2, 1, 0, sideBySideWidth, false).
+		AddItem(responseDetails, 0, 1, 2, 1, 0, sideBySideWidth, false)
+
+	grid := tview.NewGrid().SetBorders(true)
+
+	status := tview.NewTextView().SetDynamicColors(true)
+
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(grid, 0, 1, true).
+		AddItem(status, 1, 0, false)
+
+	app := tview.NewApplication().SetRoot(root, true)
+
+	maxRps := options.maxRps
+	if maxRps == 0 {
+		maxRps = defaultMaxRps
+	}
+
+	el := &eventLog{
+		app:             app,
+		title:           strings.Join(os.Args, " "),
+		root:            root,
+		recent:          newRecentIDs(),
+		grid:            grid,
+		details:         details,
+		requestDetails:  requestDetails,
+		responseDetails: responseDetails,
+		detailEvent:     -1,
+		status:          status,
+		table:           table,
+		outbound:        outbound,
+		done:            make(chan struct{}),
+		events:          []pkg.Stream{},
+		sources:         map[string]struct{}{},
+		destinations:    map[string]struct{}{},
+		start:           time.Now(),
+		limit:           options.limit,
+		filters:         filters,
+		clientFilters:   clientFilters(options),
+		keymap:          options.keys,
+		latencyUnit:     options.latencyUnit,
+		openTemplate:    options.openTemplate,
+		saver:           options.saver,
+		otel:            options.otel,
+		highlights:      highlights,
+		maxRps:          maxRps,
+		fullAddress:     options.fullAddress,
+		noColor:         options.noColor,
+		theme:           theme,
+		columns:         columns,
+		detailFields:    detailFields,
+		sortColumn:      -1,
+		location:        options.location,
+		newestFirst:     options.order == "newest-first",
+		selectLatest:    options.selectFirst,
+		dedupWindow:     options.dedupWindow,
+		detailRatio:     options.detailRatio,
+		sampleRate:      options.sampleRate,
+		idleTimeout:     options.idleTimeout,
+		summaryFile:     options.summaryFile,
+		lastActivity:    time.Now().UnixNano(),
+		responsive:      options.responsive,
+		columnWidths:    map[string]int{},
+		open:            map[pkg.StreamID]*openStream{},
+		foldedTraces:    map[string]bool{},
+		collapsed:       map[string]*collapsedRow{},
+		statusCodes:     newSummaryView(renderStatusCodes),
+		latencies:       newSummaryView(renderLatencyChart),
+	}
+	if options.anonymize {
+		el.anonymizer = newAnonymizer()
+	}
+	el.routes = newSummaryView(el.renderRoutes)
+	el.connections = newSummaryView(el.renderConnections)
+	el.heatmap = newSummaryView(el.renderHeatmap)
+	el.openStreams = newSummaryView(el.renderOpenStreams)
+	el.filterList = newSummaryView(el.renderFilters)
+	// Grouped counts replace the request table from the start. The request
+	// table can still be reached by toggling another view on and off.
+	if countBy != nil {
+		el.summary = newSummaryView(el.renderCounts(*countBy))
+		el.summary.render(el.summary.table, el.events)
+	}
+	el.layout()
+	el.updateTitle()
+	el.updateStatus()
+	el.renderHeader(table)
+	el.renderHeader(outbound)
+
+	app.SetInputCapture(el.handleKey)
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		el.fitColumns(screen)
+		return false
+	})
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		el.screen = screen
+	})
+	table.SetSelectedFunc(el.selectionChanged)
+	outbound.SetSelectedFunc(el.outboundSelectionChanged)
+
+	return el
+}
+
+// run shows the UI until the user quits or ctx is done, then prints the
+// summary. It returns an error if the terminal can't show the UI.
+func (el *eventLog) run(ctx context.Context) error {
+	defer el.recoverPanic()
+	defer el.closeDone()
+
+	screen, err := newScreen()
 	if err != nil {
-		fmt.Fprint(os.Stderr, err.Error())
-		return
+		return err
+	}
+	el.app.SetScreen(screen)
+
+	el.goUpdating(func() { el.tickClock(el.done) })
+	if el.idleTimeout > 0 && el.session != nil {
+		go el.stopWhenIdle(el.done)
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			el.quit()
+		case <-el.done:
+		}
+	}()
+	if err := el.app.Run(); err != nil {
+		return err
+	}
+	el.printSummary()
+	if el.summaryFile != "" {
+		if err := writeAggregates(el.summaryFile, el.aggregates()); err != nil {
+			return fmt.Errorf("failed to write --summary-file: %w", err)
+		}
+	}
+	return nil
+}
+
+// stopWhenIdle stops the UI once no tap has reported a request for
+// idleTimeout, or returns when done is closed.
+func (el *eventLog) stopWhenIdle(done <-chan struct{}) {
+	defer el.recoverPanic()
+	for {
+		last := time.Unix(0, atomic.LoadInt64(&el.lastActivity))
+		wait := el.idleTimeout - time.Since(last)
+		if wait <= 0 {
+			el.quit()
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// quit closes done, so that every tap stops and closes its stream, and stops
+// the UI once they have all returned. The UI keeps running until then since
+// they update it as they stop; were it stopped first, they would block. It may
+// be called from any goroutine, and more than once.
+func (el *eventLog) quit() {
+	el.closeDone()
+	go func() {
+		el.running.Wait()
+		el.app.Stop()
+	}()
+}
+
+func (el *eventLog) closeDone() {
+	el.stopOnce.Do(func() { close(el.done) })
+}
+
+// goUpdating runs f, which updates the UI until done is closed, in a goroutine
+// that quit waits for.
+func (el *eventLog) goUpdating(f func()) {
+	el.running.Add(1)
+	go func() {
+		defer el.running.Done()
+		f()
+	}()
+}
+
+// recoverPanic should be deferred at the top of every goroutine that touches
+// the UI or the tap stream. It restores the terminal before reporting the
+// panic so that a crash doesn't leave the shell unusable.
+func (el *eventLog) recoverPanic() {
+	if r := recover(); r != nil {
+		el.app.Stop()
+		fmt.Fprintf(os.Stderr, "tapshark crashed: %v\n\n%s", r, debug.Stack())
+		os.Exit(2)
+	}
+}
+
+func (el *eventLog) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// Ctrl-C always quits, and can't be rebound. It is handled here rather
+	// than left to tview, which would stop the UI before the taps.
+	if event.Key() == tcell.KeyCtrlC {
+		el.quit()
+		return nil
+	}
+	if el.editing {
+		return event
+	}
+	if action, ok := el.keymap[keyName(event)]; ok {
+		return keyActions[action](el)
+	}
+	return event
+}
+
+// startTap opens a tap stream and starts pairing up its events, returning a
+// channel of completed requests. closed receives why the tap stream ended,
+// one of the errors from pkg.RecvEvents. The goroutines it starts defer
+// recoverPanic. If inProgress is set, requests are also sent on it as they
+// start and as their response headers arrive. If saver is set, every event is
+// also saved to it as it is received.
+func startTap(ctx context.Context, k8sAPI *k8s.KubernetesAPI, req *tapPb.TapByResourceRequest, inProgress chan<- pkg.Stream, saver *eventSaver, recoverPanic func()) (<-chan pkg.Stream, <-chan error, io.Closer, error) {
+	reader, body, err := pkg.Connect(ctx, k8sAPI, req)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	defer body.Close()
 
 	eventCh := make(chan *tapPb.TapEvent)
 	requestCh := make(chan pkg.Stream, 100)
 
-	closing := make(chan struct{}, 1)
+	closing := make(chan error, 1)
+
+	received := eventCh
+	if saver != nil {
+		received = make(chan *tapPb.TapEvent)
+		go func() {
+			defer recoverPanic()
+			saver.tee(ctx, received, eventCh)
+		}()
+	}
+	go func() {
+		defer recoverPanic()
+		pkg.RecvEvents(ctx, reader, received, closing)
+	}()
+	var sink pkg.EventSink = pkg.ChanSink{Done: requestCh, Stop: ctx.Done()}
+	if inProgress != nil {
+		sink = pkg.ProgressChanSink{Done: requestCh, InProgress: inProgress, Stop: ctx.Done()}
+	}
+	go func() {
+		defer recoverPanic()
+		pkg.ProcessEvents(ctx, eventCh, sink)
+	}()
+
+	return requestCh, closing, body, nil
+}
 
-	go pkg.RecvEvents(reader, eventCh, closing)
-	go pkg.ProcessEvents(eventCh, requestCh, done)
+func (el *eventLog) processTapEvents(ctx context.Context, k8sAPI *k8s.KubernetesAPI, req *tapPb.TapByResourceRequest, done <-chan struct{}) {
+	defer el.recoverPanic()
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The tap is stopped once done is closed, even while it is connecting.
 	go func() {
-		<-closing
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
+	el.app.QueueUpdateDraw(func() { el.tapsConnecting++ })
+	inProgress := make(chan pkg.Stream, 100)
+	requestCh, closed, body, err := startTap(ctx, k8sAPI, req, inProgress, el.saver, el.recoverPanic)
+	if err != nil {
+		el.app.QueueUpdateDraw(func() {
+			el.tapsConnecting--
+			el.tapError = describeTapError(err)
+			el.updateStatus()
+		})
+		return
+	}
+	defer body.Close()
+	el.app.QueueUpdateDraw(func() {
+		el.tapsConnecting--
+		el.tapsConnected++
+		el.updateStatus()
+	})
+	defer el.app.QueueUpdateDraw(func() {
+		el.tapsConnected--
+		el.updateStatus()
+	})
+	el.consumeTap(ctx, requestCh, closed, inProgress, done)
+}
 
-	start := time.Now()
+// consumeTap shows the requests from one tap, whether live or replayed, as
+// they complete, and those on inProgress as they start. It returns once the
+// tap is closed, the limit is reached, or ctx or done is.
+func (el *eventLog) consumeTap(ctx context.Context, requestCh <-chan pkg.Stream, closed <-chan error, inProgress <-chan pkg.Stream, done <-chan struct{}) {
+	// Streams that were still open when the tap stopped will never end.
+	open := map[pkg.StreamID]struct{}{}
+	// The proxies stop reporting requests once the rate limit is reached in
+	// each one second window, so a window that comes close to the limit means
+	// we are only seeing a sample of the traffic. The tap is counted in
+	// el.sampled until a window passes that doesn't.
+	window := time.NewTicker(time.Second)
+	defer window.Stop()
+	windowCount := 0
+	sampled := false
+	defer func() {
+		el.app.QueueUpdateDraw(func() {
+			for id := range open {
+				el.endStream(id)
+			}
+			if sampled {
+				el.sampled--
+				el.updateStatus()
+			}
+		})
+	}()
 
 	for {
 		select {
 		case <-done:
 			return
+		case <-ctx.Done():
+			return
+		case err := <-closed:
+			// A stream that was closed by stopping the tap isn't a
+			// problem worth showing.
+			if !errors.Is(err, pkg.ErrStreamClosed) {
+				el.app.QueueUpdateDraw(func() {
+					el.tapError = describeTapError(err)
+					el.updateStatus()
+				})
+			}
+			return
+		case <-window.C:
+			if full := float32(windowCount) >= samplingThreshold*el.maxRps; full != sampled {
+				sampled = full
+				el.app.QueueUpdateDraw(func() {
+					if full {
+						el.sampled++
+					} else {
+						el.sampled--
+					}
+					el.updateStatus()
+				})
+			}
+			windowCount = 0
+		case req := <-inProgress:
+			now := time.Now()
+			open[req.ID()] = struct{}{}
+			el.app.QueueUpdateDraw(func() {
+				el.progressStream(req, now)
+			})
 		case req := <-requestCh:
+			atomic.StoreInt64(&el.lastActivity, time.Now().UnixNano())
+			if _, ok := open[req.ID()]; ok {
+				delete(open, req.ID())
+				el.app.QueueUpdateDraw(func() {
+					el.endStream(req.ID())
+				})
+			}
+			windowCount++
+
+			if !el.accept(req) || !el.recent.add(req) || !sample(el.sampleRate) {
+				continue
+			}
 
-			delta := time.Since(start)
+			req.Time = time.Now()
+			delta := req.Time.Sub(el.start)
 			req.TimestampMs = uint64(delta.Milliseconds())
+			if el.otel != nil {
+				el.otel.export(req, el.start.Add(delta))
+			}
+
+			el.showRequest(req)
 
-			el.events = append(el.events, req)
-			row := len(el.events)
+			if n := atomic.AddInt64(&el.captured, 1); el.limit > 0 && n >= int64(el.limit) {
+				el.quit()
+				return
+			}
+		}
+	}
 
-			timestamp := fmt.Sprintf("%.3f", float64(req.TimestampMs)/1000.0)
-			from, pod, to := fromPodTo(req)
-			verb := req.ReqInit.GetMethod().GetRegistered().String()
-			path := req.ReqInit.GetPath()
-			status := fmt.Sprintf("%d", req.RspInit.GetHttpStatus())
-			latency := latency(req)
+}
 
-			el.app.QueueUpdateDraw(func() {
-				el.table.SetCellSimple(row, 0, timestamp)
-				el.table.SetCellSimple(row, 1, pad(from))
-				el.table.SetCellSimple(row, 2, pad(pod))
-				el.table.SetCellSimple(row, 3, pad(to))
-				el.table.SetCellSimple(row, 4, pad(verb))
-				el.table.SetCellSimple(row, 5, pad(path))
-				el.table.SetCellSimple(row, 6, pad(status))
-				el.table.SetCellSimple(row, 7, latency)
-			})
+func (el *eventLog) addEvent(req pkg.Stream) {
+	el.events = append(el.events, req)
+	el.sources[peerName(req.Event.GetSource(), req.Event.GetSourceMeta())] = struct{}{}
+	el.destinations[peerName(req.Event.GetDestination(), req.Event.GetDestinationMeta())] = struct{}{}
+	el.updateStatus()
+	if el.summary != nil {
+		el.summary.render(el.summary.table, el.events)
+	}
+	if el.paused {
+		el.held++
+		return
+	}
+	el.showEvent(len(el.events) - 1)
+}
+
+// showEvent adds events[idx], the latest request out of the backlog, to the
+// table.
+func (el *eventLog) showEvent(idx int) {
+	req := el.events[idx]
+	if latency := latencyDuration(req); latency > el.maxLatency {
+		el.maxLatency = latency
+		// Every bar is rescaled to the new maximum.
+		if el.showsColumn(latencyBarHeader) {
+			defer el.render()
+		}
+	}
+	shown := el.collapse(idx, req)
+	if el.selectLatest {
+		defer el.selectEvent(shown)
+	}
+	if el.sortColumn >= 0 || el.tracesGrouped {
+		el.render()
+		return
+	}
+	if shown != idx {
+		el.refreshEvent(shown)
+		return
+	}
+	if !el.shown(req) {
+		return
+	}
+	row := tableRow{event: idx}
+	if el.split && isOutbound(req) {
+		el.addRow(el.outbound, &el.outboundRows, row)
+		return
+	}
+	el.addRow(el.table, &el.rows, row)
+}
+
+// addRow adds a new row to the end of a table in arrival order, which is the
+// top with --order newest-first. The selection stays on the same row.
+func (el *eventLog) addRow(table *tview.Table, rows *[]tableRow, row tableRow) {
+	if !el.newestFirst {
+		*rows = append(*rows, row)
+		el.setRow(table, len(*rows), row)
+		return
+	}
+	*rows = append([]tableRow{row}, *rows...)
+	table.InsertRow(1)
+	el.setRow(table, 1, row)
+	if selected, column := table.GetSelection(); selected > 0 && len(*rows) > 1 {
+		table.Select(selected+1, column)
+	}
+}
+
+// selectEvent selects the row showing events[idx], wherever the current sort
+// has put it, and shows its details.
+func (el *eventLog) selectEvent(idx int) {
+	for _, t := range []struct {
+		table *tview.Table
+		rows  []tableRow
+	}{{el.table, el.rows}, {el.outbound, el.outboundRows}} {
+		for i, row := range t.rows {
+			if row.marker == nil && row.event == idx {
+				t.table.Select(i+1, 0)
+				el.showDetails(t.rows, i+1)
+				return
+			}
 		}
 	}
+}
 
+func (el *eventLog) addMarker(m marker) {
+	el.markers = append(el.markers, m)
+	if el.sortColumn >= 0 {
+		el.render()
+		return
+	}
+	el.addRow(el.table, &el.rows, tableRow{marker: &el.markers[len(el.markers)-1]})
 }
 
-func (el *eventLog) selectionChanged(row, column int) {
-	if row == 0 {
-		el.details.Clear()
+// render rebuilds every row of the tables from events in the current sort
+// order.
+func (el *eventLog) render() {
+	// Requests held back while the view is paused aren't shown yet.
+	order := make([]int, len(el.events)-el.held)
+	for i := range order {
+		order[i] = i
+	}
+	if el.sortColumn >= 0 {
+		col := el.columns[el.sortColumn]
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := el.events[order[i]], el.events[order[j]]
+			if el.sortDesc {
+				return col.compare(el, b, a)
+			}
+			return col.compare(el, a, b)
+		})
+	} else if el.newestFirst {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	el.rows = el.rows[:0]
+	el.outboundRows = el.outboundRows[:0]
+	// Every row is about to be set again, which measures the columns anew.
+	el.columnWidths = map[string]int{}
+	for _, idx := range order {
+		if el.repeats[idx] < 0 || !el.shown(el.events[idx]) {
+			continue
+		}
+		if el.split && isOutbound(el.events[idx]) {
+			el.outboundRows = append(el.outboundRows, tableRow{event: idx})
+		} else {
+			el.rows = append(el.rows, tableRow{event: idx})
+		}
+	}
+	if el.tracesGrouped {
+		el.rows = el.groupTraces(el.rows)
+		el.outboundRows = el.groupTraces(el.outboundRows)
+	} else if el.sortColumn < 0 || el.columns[el.sortColumn].header == "TIME" {
+		// Markers only make sense among requests ordered by time.
+		el.rows = el.mergeMarkers(el.rows)
+	}
+
+	el.renderTable(el.table, el.rows)
+	if el.split {
+		el.renderTable(el.outbound, el.outboundRows)
+	}
+}
+
+// mergeMarkers interleaves the markers with rows by timestamp.
+func (el *eventLog) mergeMarkers(rows []tableRow) []tableRow {
+	markers := make([]tableRow, len(el.markers))
+	for i := range el.markers {
+		markers[i] = tableRow{marker: &el.markers[i]}
+	}
+	descending := el.sortDesc || (el.sortColumn < 0 && el.newestFirst)
+	if descending {
+		for i, j := 0, len(markers)-1; i < j; i, j = i+1, j-1 {
+			markers[i], markers[j] = markers[j], markers[i]
+		}
+	}
+	before := func(m *marker, req pkg.Stream) bool {
+		if descending {
+			return m.timestampMs > req.TimestampMs
+		}
+		return m.timestampMs < req.TimestampMs
+	}
+
+	merged := make([]tableRow, 0, len(rows)+len(markers))
+	for _, row := range rows {
+		for len(markers) > 0 && before(markers[0].marker, el.events[row.event]) {
+			merged = append(merged, markers[0])
+			markers = markers[1:]
+		}
+		merged = append(merged, row)
+	}
+	return append(merged, markers...)
+}
+
+func (el *eventLog) renderTable(table *tview.Table, rows []tableRow) {
+	el.renderHeader(table)
+	for i, row := range rows {
+		el.setRow(table, i+1, row)
+	}
+	truncateRows(table, len(rows)+1)
+}
+
+func (el *eventLog) renderHeader(table *tview.Table) {
+	for i, col := range el.columns {
+		header := col.header
+		if i == el.sortColumn {
+			if el.sortDesc {
+				header += " ▼"
+			} else {
+				header += " ▲"
+			}
+		}
+		if col.padded {
+			header = pad(header)
+		}
+		cell := tview.NewTableCell(el.columnText(col.header, header))
+		cell.SetAttributes(tcell.AttrBold)
+		updateCell(table, 0, i, cell)
+	}
+}
+
+func (el *eventLog) setRow(table *tview.Table, row int, r tableRow) {
+	if r.marker != nil {
+		el.setMarkerRow(table, row, r.marker)
 		return
 	}
-	req := el.events[row-1]
-	from, pod, to := fromPodTo(req)
-	el.details.Clear()
+	req := el.events[r.event]
+	rowColor := el.rowColor(req)
+	color, highlighted := el.highlightColor(req)
+	for i, col := range el.columns {
+		text := col.cell(el, req)
+		// Collapsed requests are counted after the path.
+		if col.header == "PATH" && el.repeats[r.event] > 0 {
+			text = pad(fmt.Sprintf("%s (×%d)", col.value(el, req), el.repeats[r.event]+1))
+		}
+		if col.header == "PATH" && el.tracesGrouped {
+			text = pad(el.tracePrefix(r) + strings.TrimSpace(text))
+		}
+		// The verb of a request with an unexpected body is marked.
+		if col.header == "VERB" {
+			if _, ok := bodyWarning(req); ok {
+				text = pad(col.value(el, req) + " " + bodyWarningMarker)
+			}
+		}
+		cell := tview.NewTableCell(el.columnText(col.header, text)).SetTextColor(rowColor)
+		if col.color != nil && !el.noColor {
+			cell.SetTextColor(col.color(el, req))
+		}
+		if highlighted {
+			cell.SetTextColor(color).SetAttributes(tcell.AttrBold)
+		}
+		updateCell(table, row, i, cell)
+	}
+}
 
-	fieldTemplate := "[::b]%s:[-:-:-] %s\n"
+// setMarkerRow fills a row with a marker. The text goes in the PATH column,
+// which is usually the widest, and the row can't be selected.
+func (el *eventLog) setMarkerRow(table *tview.Table, row int, m *marker) {
+	for i, col := range el.columns {
+		var text string
+		switch col.header {
+		case "TIME":
+			text = el.formatTime(m.timestampMs, el.start.Add(time.Duration(m.timestampMs)*time.Millisecond))
+		case "PATH":
+			text = pad(m.text)
+		}
+		cell := tview.NewTableCell(el.columnText(col.header, text)).
+			SetTextColor(tview.Styles.SecondaryTextColor).
+			SetSelectable(false)
+		updateCell(table, row, i, cell)
+	}
+}
 
-	fmt.Fprintf(el.details, fieldTemplate, "Pod", pod)
-	if from != "" {
-		fmt.Fprintf(el.details, fieldTemplate, "From", from)
+// cycleSort moves the sort to the next column, wrapping back around to
+// arrival order after the last column.
+func (el *eventLog) cycleSort() {
+	el.sortColumn++
+	if el.sortColumn >= len(el.columns) {
+		el.sortColumn = -1
 	}
-	if to != "" {
-		fmt.Fprintf(el.details, fieldTemplate, "To", to)
+	el.sortDesc = false
+	el.render()
+}
+
+func (el *eventLog) reverseSort() {
+	if el.sortColumn < 0 {
+		return
 	}
-	fmt.Fprintln(el.details)
+	el.sortDesc = !el.sortDesc
+	el.render()
+}
 
-	fmt.Fprintf(el.details, fieldTemplate, "Source", addr.PublicAddressToString(req.Event.GetSource()))
-	fmt.Fprintf(el.details, fieldTemplate, "Source Metadata", "")
-	for k, v := range req.Event.GetSourceMeta().GetLabels() {
-		fmt.Fprintf(el.details, "\t%s: %s\n", k, v)
+// panes returns the visible panes from top to bottom. When split, inbound
+// requests are shown in the top table and outbound requests in the one below
+// it. A summary view replaces the request tables entirely.
+func (el *eventLog) panes() []tview.Primitive {
+	if el.summary != nil {
+		return []tview.Primitive{el.summary.table, el.details}
 	}
-	fmt.Fprintf(el.details, fieldTemplate, "Destination", addr.PublicAddressToString(req.Event.GetDestination()))
-	fmt.Fprintf(el.details, fieldTemplate, "Destination Metadata", "")
-	for k, v := range req.Event.GetDestinationMeta().GetLabels() {
-		fmt.Fprintf(el.details, "\t%s: %s\n", k, v)
+	if el.split {
+		return []tview.Primitive{el.table, el.outbound, el.details}
 	}
-	fmt.Fprintln(el.details)
+	return []tview.Primitive{el.table, el.details}
+}
 
-	if len(req.Event.GetRouteMeta().GetLabels()) > 0 {
-		fmt.Fprintf(el.details, fieldTemplate, "Route Metadata", "")
-		for k, v := range req.Event.GetRouteMeta().GetLabels() {
-			fmt.Fprintf(el.details, "\t%s: %s\n", k, v)
+// layout arranges the visible panes in the grid, moving focus to the top pane
+// if the focused pane is no longer shown.
+func (el *eventLog) layout() {
+	panes := el.panes()
+	el.grid.Clear()
+	el.grid.SetSize(len(panes), 1, -1, -1)
+	if el.detailRatio > 0 {
+		// The details pane is always last; the tables above it share the
+		// rest of the height equally.
+		rows := make([]int, len(panes))
+		details := int(math.Round(el.detailRatio * 100))
+		for i := range rows {
+			rows[i] = -(100 - details) / (len(panes) - 1)
 		}
-		fmt.Fprintln(el.details)
+		rows[len(rows)-1] = -details
+		el.grid.SetRows(rows...)
+	}
+	focused := false
+	for i, p := range panes {
+		el.grid.AddItem(p, i, 0, 1, 1, 0, 0, i == 0)
+		focused = focused || p.HasFocus()
+	}
+	if !focused {
+		el.app.SetFocus(panes[0])
+	}
+}
+
+// resizeDetails grows the details pane by delta, a fraction of the height,
+// or shrinks it if delta is negative.
+func (el *eventLog) resizeDetails(delta float64) {
+	ratio := el.detailRatio
+	if ratio == 0 {
+		ratio = 1 / float64(len(el.panes()))
 	}
+	el.detailRatio = math.Max(minDetailRatio, math.Min(maxDetailRatio, ratio+delta))
+	el.layout()
+}
+
+func (el *eventLog) toggleSplit() {
+	el.split = !el.split
+	el.layout()
+	el.render()
+}
 
-	fmt.Fprintf(el.details, fieldTemplate, "Scheme", req.ReqInit.GetScheme().GetRegistered().String())
-	fmt.Fprintf(el.details, fieldTemplate, "Verb", req.ReqInit.GetMethod().GetRegistered().String())
-	fmt.Fprintf(el.details, fieldTemplate, "Path", req.ReqInit.GetPath())
-	fmt.Fprintf(el.details, fieldTemplate, "Authority", req.ReqInit.GetAuthority())
-	fmt.Fprintf(el.details, fieldTemplate, "Request Headers", "")
-	for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
-		fmt.Fprintf(el.details, "\t%s: %s\n", header.GetName(), header.GetValueStr())
+// toggleAddresses switches peers between their pod names and their ip:port,
+// as with --full-address. Requests are collapsed by how their peers are
+// shown, so rows collapsed before the switch aren't added to after it.
+func (el *eventLog) toggleAddresses() {
+	el.fullAddress = !el.fullAddress
+	el.collapsed = map[string]*collapsedRow{}
+	el.render()
+	if el.detailEvent >= 0 {
+		el.requestDetails.Clear()
+		el.responseDetails.Clear()
+		el.writeDetails(el.events[el.detailEvent], el.requestDetails, el.responseDetails, newTextDetails)
 	}
-	fmt.Fprintf(el.details, fieldTemplate, "Latency", latency(req))
-	fmt.Fprintf(el.details, fieldTemplate, "Status", fmt.Sprintf("%d", req.RspInit.GetHttpStatus()))
+	if el.summary != nil {
+		el.summary.render(el.summary.table, el.events)
+	}
+}
 
-	var duration string
-	d, err := ptypes.Duration(req.RspEnd.GetSinceResponseInit())
-	if err == nil {
-		duration = d.String()
+// scrollDetails scrolls both halves of the details pane half a page down (or
+// up, for a negative direction) without moving focus away from the tables.
+func (el *eventLog) scrollDetails(direction int) {
+	for _, view := range []*tview.TextView{el.requestDetails, el.responseDetails} {
+		_, _, _, height := view.GetInnerRect()
+		step := height / 2
+		if step < 1 {
+			step = 1
+		}
+		row, column := view.GetScrollOffset()
+		row += direction * step
+		if row < 0 {
+			row = 0
+		}
+		view.ScrollTo(row, column)
+	}
+}
+
+// cycleFocus moves focus to the next visible pane. The request and response
+// halves of the details pane are focused separately so each can be scrolled.
+func (el *eventLog) cycleFocus() {
+	var panes []tview.Primitive
+	for _, p := range el.panes() {
+		if p == el.details {
+			panes = append(panes, el.requestDetails, el.responseDetails)
+		} else {
+			panes = append(panes, p)
+		}
 	}
+	for i, p := range panes {
+		if p.HasFocus() {
+			el.app.SetFocus(panes[(i+1)%len(panes)])
+			return
+		}
+	}
+	el.app.SetFocus(panes[0])
+}
 
-	fmt.Fprintf(el.details, fieldTemplate, "Duration", duration)
-	fmt.Fprintf(el.details, fieldTemplate, "Response Headers", "")
-	for _, header := range req.RspInit.GetHeaders().GetHeaders() {
-		fmt.Fprintf(el.details, "\t%s: %s\n", header.GetName(), header.GetValueStr())
+func (el *eventLog) selectionChanged(row, column int) {
+	el.showDetails(el.rows, row)
+}
+
+func (el *eventLog) outboundSelectionChanged(row, column int) {
+	el.showDetails(el.outboundRows, row)
+}
+
+func (el *eventLog) showDetails(rows []tableRow, row int) {
+	el.requestDetails.Clear()
+	el.responseDetails.Clear()
+	el.detailEvent = -1
+	if row == 0 {
+		return
 	}
-	fmt.Fprintf(el.details, fieldTemplate, "Response Trailers", "")
-	for _, header := range req.RspEnd.Trailers.GetHeaders() {
-		fmt.Fprintf(el.details, "\t%s: %s\n", header.GetName(), header.GetValueStr())
+	r := rows[row-1]
+	if r.marker != nil {
+		return
+	}
+	el.detailEvent = r.event
+	el.writeDetails(el.events[r.event], el.requestDetails, el.responseDetails, newTextDetails)
+	el.requestDetails.ScrollToBeginning()
+	el.responseDetails.ScrollToBeginning()
+}
+
+// describeTapError explains why a tap failed to start or stopped, for the
+// status line.
+func describeTapError(err error) string {
+	switch {
+	case errors.Is(err, pkg.ErrUnauthorized):
+		return fmt.Sprintf("%v; check that your RBAC allows tapping this resource", err)
+	case errors.Is(err, pkg.ErrStreamEnded):
+		return "the tap server ended the stream"
+	default:
+		return err.Error()
 	}
-	el.details.ScrollToBeginning()
 }
 
 func pad(s string) string {
 	return fmt.Sprintf(" %s ", s)
 }
 
-func fromPodTo(req pkg.Stream) (string, string, string) {
-	source := stripPort(addr.PublicAddressToString(req.Event.GetSource()))
-	if pod := req.Event.SourceMeta.Labels["pod"]; pod != "" {
-		source = pod
+// fromPodTo returns the peers of req as shown in the FROM, POD, and TO
+// columns. The tapped pod is the server of inbound requests and the client of
+// outbound ones.
+func (el *eventLog) fromPodTo(req pkg.Stream) (string, string, string) {
+	source := el.anonymizer.address(req.Event.GetSource())
+	destination := el.anonymizer.address(req.Event.GetDestination())
+	if !el.fullAddress {
+		source = stripPort(source)
+		if workload := el.transientWorkload(req.Event.GetSourceMeta()); workload != "" {
+			source = workload
+		} else if pod := req.Event.SourceMeta.Labels["pod"]; pod != "" {
+			source = el.anonymizer.pod(pod)
+		}
+		destination = stripPort(destination)
+		if workload := el.transientWorkload(req.Event.GetDestinationMeta()); workload != "" {
+			destination = workload
+		} else if pod := req.Event.DestinationMeta.Labels["pod"]; pod != "" {
+			destination = el.anonymizer.pod(pod)
+		}
 	}
-	destination := stripPort(addr.PublicAddressToString(req.Event.GetDestination()))
-	if pod := req.Event.DestinationMeta.Labels["pod"]; pod != "" {
-		destination = pod
+	switch req.Event.GetProxyDirection() {
+	case tapPb.TapEvent_INBOUND:
+		return source, destination, ""
+	case tapPb.TapEvent_OUTBOUND:
+		return "", source, destination
+	default:
+		// Without a direction it isn't known which peer is the tapped
+		// pod, so both are shown as they are.
+		return source, "?", destination
 	}
-	var from, pod, to string
-	if req.Event.GetProxyDirection() == tapPb.TapEvent_INBOUND {
-		from = source
-		pod = destination
-	} else if req.Event.GetProxyDirection() == tapPb.TapEvent_OUTBOUND {
-		pod = source
-		to = destination
+}
+
+// transientWorkload names the CronJob or Job that a peer's pod belongs to, if
+// any. Their pods have generated names that say little about what they are.
+func (el *eventLog) transientWorkload(meta *tapPb.TapEvent_EndpointMeta) string {
+	labels := meta.GetLabels()
+	if cronjob := labels["cronjob"]; cronjob != "" {
+		return "cronjob/" + el.anonymizer.label("cronjob", cronjob)
+	}
+	// The proxy labels the owning Job as k8s_job.
+	if job := labels["k8s_job"]; job != "" {
+		return "job/" + el.anonymizer.label("k8s_job", job)
+	}
+	return ""
+}
+
+// peerName identifies a peer by its pod, or by its IP if it isn't a known pod.
+func peerName(address *netPb.TcpAddress, meta *tapPb.TapEvent_EndpointMeta) string {
+	if pod := meta.GetLabels()["pod"]; pod != "" {
+		return pod
 	}
-	return from, pod, to
+	return stripPort(addr.PublicAddressToString(address))
+}
+
+func isOutbound(req pkg.Stream) bool {
+	return req.Event.GetProxyDirection() == tapPb.TapEvent_OUTBOUND
 }
 
 func latency(req pkg.Stream) string {
@@ -377,6 +1724,84 @@ func latency(req pkg.Stream) string {
 	return latency.String()
 }
 
+// latencyUnits are the units --latency-unit accepts, with the number of
+// decimals each is shown with.
+var latencyUnits = map[string]struct {
+	unit     time.Duration
+	decimals int
+}{
+	"us": {time.Microsecond, 0},
+	"ms": {time.Millisecond, 3},
+	"s":  {time.Second, 6},
+}
+
+// formatDuration formats a latency in the --latency-unit, if one was given,
+// or else in whichever unit suits it, as time.Duration does.
+func (el *eventLog) formatDuration(d time.Duration) string {
+	unit, ok := latencyUnits[el.latencyUnit]
+	if !ok {
+		return d.String()
+	}
+	return strconv.FormatFloat(float64(d)/float64(unit.unit), 'f', unit.decimals, 64) + el.latencyUnit
+}
+
+// formatLatency formats the latency of req like formatDuration, or returns
+// nothing if it isn't known.
+func (el *eventLog) formatLatency(req pkg.Stream) string {
+	latency, err := ptypes.Duration(req.RspEnd.GetSinceRequestInit())
+	if err != nil {
+		return ""
+	}
+	return el.formatDuration(latency)
+}
+
+func latencyDuration(req pkg.Stream) time.Duration {
+	latency, err := ptypes.Duration(req.RspEnd.GetSinceRequestInit())
+	if err != nil {
+		return 0
+	}
+	return latency
+}
+
+func method(req pkg.Stream) string {
+	if unregistered := req.ReqInit.GetMethod().GetUnregistered(); unregistered != "" {
+		return unregistered
+	}
+	return req.ReqInit.GetMethod().GetRegistered().String()
+}
+
+func scheme(req pkg.Stream) string {
+	if unregistered := req.ReqInit.GetScheme().GetUnregistered(); unregistered != "" {
+		return unregistered
+	}
+	return req.ReqInit.GetScheme().GetRegistered().String()
+}
+
+// splitAuthority splits an :authority into its host and, if there is one, its
+// port. The brackets around IPv6 literals are removed.
+func splitAuthority(authority string) (string, string) {
+	if host, port, err := net.SplitHostPort(authority); err == nil {
+		return host, port
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(authority, "["), "]"), ""
+}
+
+// defaultPort describes the port a request without one in its :authority was
+// sent to.
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return "80 (default)"
+	case "https":
+		return "443 (default)"
+	}
+	return ""
+}
+
 func stripPort(address string) string {
-	return strings.Split(address, ":")[0]
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newScreen opens the terminal for the UI. If the terminal can't show it, the
+// error says so and suggests --no-tui instead, rather than failing with
+// tcell's error alone.
+func newScreen() (tcell.Screen, error) {
+	if term := os.Getenv("TERM"); term == "dumb" {
+		return nil, fmt.Errorf("the terminal (TERM=%s) can't show tapshark's interactive UI; use --no-tui to print requests as JSON lines instead", term)
+	}
+	screen, err := tcell.NewScreen()
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the terminal for tapshark's interactive UI: %w; use --no-tui to print requests as JSON lines instead", err)
+	}
+	return screen, nil
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal rather than, say, a
+// pipe or a file.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
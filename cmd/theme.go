@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const defaultTheme = "dark"
+
+// A theme sets the colors of every primitive as well as the colors used to
+// classify responses by status.
+type theme struct {
+	tview.Theme
+
+	success     tcell.Color // 1xx and 2xx
+	redirect    tcell.Color // 3xx
+	clientError tcell.Color // 4xx
+	serverError tcell.Color // 5xx
+	noResponse  tcell.Color // the stream ended before a response
+}
+
+var themes = map[string]theme{
+	"dark": {
+		Theme:       tview.Styles,
+		success:     tcell.ColorGreen,
+		redirect:    tcell.ColorYellow,
+		clientError: tcell.ColorYellow,
+		serverError: tcell.ColorRed,
+		noResponse:  tcell.ColorGray,
+	},
+	"light": {
+		Theme: tview.Theme{
+			PrimitiveBackgroundColor:    tcell.ColorWhite,
+			ContrastBackgroundColor:     tcell.ColorLightGray,
+			MoreContrastBackgroundColor: tcell.ColorSilver,
+			BorderColor:                 tcell.ColorBlack,
+			TitleColor:                  tcell.ColorBlack,
+			GraphicsColor:               tcell.ColorGray,
+			PrimaryTextColor:            tcell.ColorBlack,
+			SecondaryTextColor:          tcell.ColorNavy,
+			TertiaryTextColor:           tcell.ColorDarkGreen,
+			InverseTextColor:            tcell.ColorWhite,
+			ContrastSecondaryTextColor:  tcell.ColorNavy,
+		},
+		success:     tcell.ColorDarkGreen,
+		redirect:    tcell.ColorOlive,
+		clientError: tcell.ColorOlive,
+		serverError: tcell.ColorMaroon,
+		noResponse:  tcell.ColorGray,
+	},
+	"high-contrast": {
+		Theme: tview.Theme{
+			PrimitiveBackgroundColor:    tcell.ColorBlack,
+			ContrastBackgroundColor:     tcell.ColorWhite,
+			MoreContrastBackgroundColor: tcell.ColorYellow,
+			BorderColor:                 tcell.ColorYellow,
+			TitleColor:                  tcell.ColorWhite,
+			GraphicsColor:               tcell.ColorYellow,
+			PrimaryTextColor:            tcell.ColorWhite,
+			SecondaryTextColor:          tcell.ColorYellow,
+			TertiaryTextColor:           tcell.ColorAqua,
+			InverseTextColor:            tcell.ColorBlack,
+			ContrastSecondaryTextColor:  tcell.ColorBlack,
+		},
+		success:     tcell.ColorLime,
+		redirect:    tcell.ColorAqua,
+		clientError: tcell.ColorYellow,
+		serverError: tcell.ColorFuchsia,
+		noResponse:  tcell.ColorSilver,
+	},
+	// colorblind uses the Okabe-Ito palette, which stays distinguishable for
+	// the common forms of color blindness.
+	"colorblind": {
+		Theme:       tview.Styles,
+		success:     tcell.NewHexColor(0x56B4E9),
+		redirect:    tcell.NewHexColor(0xF0E442),
+		clientError: tcell.NewHexColor(0xE69F00),
+		serverError: tcell.NewHexColor(0xD55E00),
+		noResponse:  tcell.NewHexColor(0x999999),
+	},
+}
+
+func lookupTheme(name string) (theme, error) {
+	t, ok := themes[name]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for name := range themes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return theme{}, fmt.Errorf("unknown theme %q; valid themes are: %s", name, strings.Join(names, ", "))
+	}
+	return t, nil
+}
+
+// statusColor returns the color used for a request's status.
+func (t theme) statusColor(req pkg.Stream) tcell.Color {
+	switch status := req.RspInit.GetHttpStatus(); {
+	case req.RspInit == nil:
+		return t.noResponse
+	case status >= 500:
+		return t.serverError
+	case status >= 400:
+		return t.clientError
+	case status >= 300:
+		return t.redirect
+	}
+	return t.success
+}
+
+// rowColor returns the color a request's row is shown in: the color of its
+// status, or the usual text color with --no-color.
+func (el *eventLog) rowColor(req pkg.Stream) tcell.Color {
+	if el.noColor {
+		return tview.Styles.PrimaryTextColor
+	}
+	return el.theme.statusColor(req)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import "github.com/adleong/tapshark/pkg"
+
+// tlsVersionLabels and tlsCipherLabels are the metadata labels that TLS
+// negotiation details are looked for in. Linkerd doesn't define them, and its
+// proxy doesn't report the negotiated version or cipher, so the fields that
+// show them are optional.
+var (
+	tlsVersionLabels = []string{"tls_version", "tls.version"}
+	tlsCipherLabels  = []string{"tls_cipher", "tls_cipher_suite", "tls.cipher"}
+)
+
+// tlsDetail returns the first of the labels that is set on the remote end of
+// req's connection, or n/a if none is.
+func tlsDetail(req pkg.Stream, labels []string) string {
+	for _, label := range labels {
+		if value := remoteLabel(req, label); value != "" {
+			return value
+		}
+	}
+	return "n/a"
+}
+
+// describeTLS describes the proxy's tls label: whether the connection is
+// meshed mTLS, or why not.
+func describeTLS(req pkg.Stream) string {
+	switch status := tlsStatus(req); status {
+	case "true":
+		return "mTLS"
+	case "":
+		return "n/a"
+	default:
+		return "no mTLS (" + status + ")"
+	}
+}
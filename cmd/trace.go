@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adleong/tapshark/pkg"
+)
+
+// traceHeaders are the request headers that correlated requests share, in
+// the order they are looked for.
+var traceHeaders = []string{"x-request-id", "traceparent", "b3", "x-b3-traceid", "l5d-ctx-trace", "x-cloud-trace-context"}
+
+// traceID returns the identifier of the trace req belongs to, if it carries
+// one of traceHeaders. Only the trace part of headers that also identify the
+// span, such as traceparent, is used, so that every hop of the trace has the
+// same ID.
+func traceID(req pkg.Stream) (string, bool) {
+	for _, name := range traceHeaders {
+		for _, header := range req.ReqInit.GetHeaders().GetHeaders() {
+			if !strings.EqualFold(header.GetName(), name) {
+				continue
+			}
+			value := header.GetValueStr()
+			switch name {
+			case "traceparent":
+				// version-traceid-spanid-flags
+				if parts := strings.Split(value, "-"); len(parts) == 4 {
+					value = parts[1]
+				}
+			case "b3":
+				// traceid-spanid-sampled-parentspanid
+				value = strings.SplitN(value, "-", 2)[0]
+			case "x-cloud-trace-context":
+				// traceid/spanid;o=options
+				value = strings.SplitN(value, "/", 2)[0]
+			}
+			if value != "" {
+				return name + "=" + value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// groupTraces reorders rows so that each request that shares a trace with an
+// earlier row follows that row, indented under it, and hides the rest of
+// each folded trace. Rows without a trace are left where they are.
+func (el *eventLog) groupTraces(rows []tableRow) []tableRow {
+	sizes := map[string]int{}
+	children := map[string][]tableRow{}
+	isChild := make([]bool, len(rows))
+	for i, row := range rows {
+		if id, ok := traceID(el.events[row.event]); ok {
+			if sizes[id] > 0 {
+				isChild[i] = true
+				row.traceChild = true
+				children[id] = append(children[id], row)
+			}
+			sizes[id]++
+		}
+	}
+
+	grouped := make([]tableRow, 0, len(rows))
+	for i, row := range rows {
+		if isChild[i] {
+			continue
+		}
+		id, ok := traceID(el.events[row.event])
+		if !ok {
+			grouped = append(grouped, row)
+			continue
+		}
+		row.traceSize = sizes[id]
+		grouped = append(grouped, row)
+		if !el.foldedTraces[id] {
+			grouped = append(grouped, children[id]...)
+		}
+	}
+	return grouped
+}
+
+// tracePrefix returns what goes before the path of req's row when requests
+// are grouped by trace: a branch for the later requests of a trace, and for
+// the first, whether the rest are shown.
+func (el *eventLog) tracePrefix(r tableRow) string {
+	if r.traceChild {
+		return "└ "
+	}
+	if r.traceSize < 2 {
+		return ""
+	}
+	if id, _ := traceID(el.events[r.event]); el.foldedTraces[id] {
+		return fmt.Sprintf("▸ (+%d) ", r.traceSize-1)
+	}
+	return "▾ "
+}
+
+// toggleTraces groups correlated requests under the first request of their
+// trace, or shows every request in its own row again.
+func (el *eventLog) toggleTraces() {
+	el.tracesGrouped = !el.tracesGrouped
+	selected := el.detailEvent
+	el.render()
+	if selected >= 0 {
+		el.selectEvent(selected)
+	}
+	if el.tracesGrouped {
+		el.notice = "grouping requests by trace" + el.keyHint("fold", "fold or unfold one")
+	} else {
+		el.notice = ""
+	}
+	el.updateStatus()
+}
+
+// toggleFold hides or shows the rest of the trace of the request in the
+// details pane.
+func (el *eventLog) toggleFold() {
+	if !el.tracesGrouped || el.detailEvent < 0 {
+		return
+	}
+	id, ok := traceID(el.events[el.detailEvent])
+	if !ok {
+		return
+	}
+	el.foldedTraces[id] = !el.foldedTraces[id]
+	// The selection moves to the first request of the trace, since the
+	// selected request may have just been hidden.
+	root := -1
+	for _, row := range append(el.rows, el.outboundRows...) {
+		if row.marker == nil && !row.traceChild {
+			if rootID, ok := traceID(el.events[row.event]); ok && rootID == id {
+				root = row.event
+				break
+			}
+		}
+	}
+	el.render()
+	if root >= 0 {
+		el.selectEvent(root)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adleong/tapshark/pkg"
+	metricsPb "github.com/linkerd/linkerd2/viz/metrics-api/gen/viz"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// tracedRequest returns a request with the given x-request-id, or none if id
+// is empty.
+func tracedRequest(id string) pkg.Stream {
+	headers := &metricsPb.Headers{}
+	if id != "" {
+		headers.Headers = []*metricsPb.Headers_Header{{
+			Name:  "x-request-id",
+			Value: &metricsPb.Headers_Header_ValueStr{ValueStr: id},
+		}}
+	}
+	return pkg.Stream{
+		Event:   &tapPb.TapEvent{},
+		ReqInit: &tapPb.TapEvent_Http_RequestInit{Headers: headers},
+	}
+}
+
+func TestGroupTraces(t *testing.T) {
+	// Requests 0 and 2 share trace a, 1 and 4 share trace b, and 3 has no
+	// trace.
+	el := &eventLog{
+		events: []pkg.Stream{
+			tracedRequest("a"),
+			tracedRequest("b"),
+			tracedRequest("a"),
+			tracedRequest(""),
+			tracedRequest("b"),
+		},
+		foldedTraces: map[string]bool{},
+	}
+	rows := func() []tableRow {
+		rows := make([]tableRow, len(el.events))
+		for i := range rows {
+			rows[i] = tableRow{event: i}
+		}
+		return rows
+	}
+
+	testCases := []struct {
+		name     string
+		folded   []string
+		expected []tableRow
+	}{
+		{
+			name: "unfolded",
+			expected: []tableRow{
+				{event: 0, traceSize: 2},
+				{event: 2, traceChild: true},
+				{event: 1, traceSize: 2},
+				{event: 4, traceChild: true},
+				{event: 3},
+			},
+		},
+		{
+			name:   "folded",
+			folded: []string{"x-request-id=a"},
+			expected: []tableRow{
+				{event: 0, traceSize: 2},
+				{event: 1, traceSize: 2},
+				{event: 4, traceChild: true},
+				{event: 3},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			el.foldedTraces = map[string]bool{}
+			for _, id := range tc.folded {
+				el.foldedTraces[id] = true
+			}
+			grouped := el.groupTraces(rows())
+			if !reflect.DeepEqual(grouped, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, grouped)
+			}
+		})
+	}
+}
+
+func TestTracePrefix(t *testing.T) {
+	el := &eventLog{
+		events:       []pkg.Stream{tracedRequest("a"), tracedRequest("a")},
+		foldedTraces: map[string]bool{},
+	}
+	if prefix := el.tracePrefix(tableRow{event: 0, traceSize: 2}); prefix != "▾ " {
+		t.Errorf("expected an unfolded root, got %q", prefix)
+	}
+	if prefix := el.tracePrefix(tableRow{event: 1, traceChild: true}); prefix != "└ " {
+		t.Errorf("expected a child, got %q", prefix)
+	}
+	el.foldedTraces["x-request-id=a"] = true
+	if prefix := el.tracePrefix(tableRow{event: 0, traceSize: 2}); prefix != "▸ (+1) " {
+		t.Errorf("expected a folded root, got %q", prefix)
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adleong/tapshark/pkg"
+	"github.com/gdamore/tcell/v2"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	"github.com/rivo/tview"
+)
+
+// A summaryView is a table that aggregates all of the captured events. It is
+// rebuilt from scratch whenever a new event arrives while it is shown.
+type summaryView struct {
+	table  *tview.Table
+	render func(table *tview.Table, events []pkg.Stream)
+}
+
+func newSummaryView(render func(table *tview.Table, events []pkg.Stream)) *summaryView {
+	return &summaryView{
+		table:  tview.NewTable().SetFixed(1, 0).SetSelectable(true, false),
+		render: render,
+	}
+}
+
+// toggleSummary shows the given summary view in place of the request tables,
+// or returns to the request tables if it is already shown.
+func (el *eventLog) toggleSummary(view *summaryView) {
+	if el.summary == view {
+		el.summary = nil
+	} else {
+		el.summary = view
+		view.render(view.table, el.events)
+	}
+	el.layout()
+}
+
+// renderStatusCodes shows the number of responses with each HTTP status,
+// most common first.
+func renderStatusCodes(table *tview.Table, events []pkg.Stream) {
+	counts := make(map[string]int)
+	for _, req := range events {
+		counts[status(req)]++
+	}
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if counts[codes[i]] != counts[codes[j]] {
+			return counts[codes[i]] > counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+
+	setHeader(table, "STATUS", pad("COUNT"), "PERCENT")
+	for i, code := range codes {
+		percent := 100 * float64(counts[code]) / float64(len(events))
+		table.SetCellSimple(i+1, 0, code)
+		table.SetCellSimple(i+1, 1, pad(fmt.Sprintf("%d", counts[code])))
+		table.SetCellSimple(i+1, 2, fmt.Sprintf("%.1f%%", percent))
+	}
+	truncateRows(table, len(codes)+1)
+}
+
+// renderCounts returns a render function that shows the number of requests
+// with each value of col, most common first. The count is also split into
+// inbound requests, which the tapped pod served, and outbound requests, which
+// it made.
+func (el *eventLog) renderCounts(col column) func(table *tview.Table, events []pkg.Stream) {
+	return func(table *tview.Table, events []pkg.Stream) {
+		counts := make(map[string]int)
+		inbound := make(map[string]int)
+		outbound := make(map[string]int)
+		for _, req := range events {
+			value := col.value(el, req)
+			counts[value]++
+			switch req.Event.GetProxyDirection() {
+			case tapPb.TapEvent_INBOUND:
+				inbound[value]++
+			case tapPb.TapEvent_OUTBOUND:
+				outbound[value]++
+			}
+		}
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if counts[values[i]] != counts[values[j]] {
+				return counts[values[i]] > counts[values[j]]
+			}
+			return values[i] < values[j]
+		})
+
+		setHeader(table, pad(col.header), pad("COUNT"), pad("INBOUND"), pad("OUTBOUND"), "PERCENT")
+		for i, value := range values {
+			percent := 100 * float64(counts[value]) / float64(len(events))
+			table.SetCellSimple(i+1, 0, pad(value))
+			table.SetCellSimple(i+1, 1, pad(fmt.Sprintf("%d", counts[value])))
+			table.SetCellSimple(i+1, 2, pad(fmt.Sprintf("%d", inbound[value])))
+			table.SetCellSimple(i+1, 3, pad(fmt.Sprintf("%d", outbound[value])))
+			table.SetCellSimple(i+1, 4, fmt.Sprintf("%.1f%%", percent))
+		}
+		truncateRows(table, len(values)+1)
+	}
+}
+
+// countByColumn returns the column that --count-by groups requests by. Any
+// column can be used; "method" is accepted as another name for VERB.
+func countByColumn(name string) (column, error) {
+	if strings.EqualFold(name, "method") {
+		name = "VERB"
+	}
+	for _, col := range columns {
+		if strings.EqualFold(col.header, name) {
+			return col, nil
+		}
+	}
+	return column{}, fmt.Errorf("unknown --count-by value %q; valid values are %s", name, strings.Join(columnHeaders(), ", "))
+}
+
+// renderRoutes shows request counts, success rate, and latency percentiles
+// for each route, busiest first.
+func (el *eventLog) renderRoutes(table *tview.Table, events []pkg.Stream) {
+	routes := aggregate(events, "route", routeName)
+	setHeader(table, "ROUTE", pad("COUNT"), pad("SUCCESS"), pad("P50"), pad("P95"), "P99")
+	for i, route := range routes {
+		table.SetCellSimple(i+1, 0, route.Group)
+		table.SetCellSimple(i+1, 1, pad(fmt.Sprintf("%d", route.Count)))
+		table.SetCellSimple(i+1, 2, pad(fmt.Sprintf("%.1f%%", route.successRate())))
+		table.SetCellSimple(i+1, 3, pad(el.formatDuration(route.p50)))
+		table.SetCellSimple(i+1, 4, pad(el.formatDuration(route.p95)))
+		table.SetCellSimple(i+1, 5, el.formatDuration(route.p99))
+	}
+	truncateRows(table, len(routes)+1)
+}
+
+// routeName identifies the route a request matched from its route metadata.
+func routeName(req pkg.Stream) string {
+	labels := req.Event.GetRouteMeta().GetLabels()
+	if route := labels["route"]; route != "" {
+		return route
+	}
+	if len(labels) == 0 {
+		return "[unrouted]"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// percentile returns the pth percentile of a sorted list of durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func setHeader(table *tview.Table, headers ...string) {
+	for i, header := range headers {
+		cell := tview.NewTableCell(header)
+		cell.SetAttributes(tcell.AttrBold)
+		table.SetCell(0, i, cell)
+	}
+}
+
+// updateCell puts cell in the table unless the cell already there looks the
+// same, so that rebuilding a table only touches the cells that changed.
+func updateCell(table *tview.Table, row, column int, cell *tview.TableCell) {
+	current := table.GetCell(row, column)
+	if row < table.GetRowCount() && column < table.GetColumnCount() &&
+		current.Text == cell.Text &&
+		current.Color == cell.Color &&
+		current.Attributes == cell.Attributes &&
+		current.NotSelectable == cell.NotSelectable {
+		return
+	}
+	table.SetCell(row, column, cell)
+}
+
+// truncateRows removes rows from the end of the table until it has at most n
+// rows.
+func truncateRows(table *tview.Table, n int) {
+	for table.GetRowCount() > n {
+		table.RemoveRow(table.GetRowCount() - 1)
+	}
+}
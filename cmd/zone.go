@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/adleong/tapshark/pkg"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+)
+
+// zoneLabels are the labels that may hold the zone of a peer, most specific
+// first. Which ones are present depends on the proxy and on whether the
+// labels were sanitized for Prometheus.
+var zoneLabels = []string{
+	"topology.kubernetes.io/zone",
+	"topology_kubernetes_io_zone",
+	"zone",
+}
+
+// zone returns the topology zone of a peer, or nothing if its metadata
+// doesn't say.
+func zone(meta *tapPb.TapEvent_EndpointMeta) string {
+	for _, label := range zoneLabels {
+		if zone := meta.GetLabels()[label]; zone != "" {
+			return zone
+		}
+	}
+	return ""
+}
+
+// zones returns the zones of the client and server of req.
+func zones(req pkg.Stream) (string, string) {
+	return zone(req.Event.GetSourceMeta()), zone(req.Event.GetDestinationMeta())
+}
+
+// crossZone reports whether req went between two different zones. Requests
+// with a peer of unknown zone aren't counted as crossing.
+func crossZone(req pkg.Stream) bool {
+	source, destination := zones(req)
+	return source != "" && destination != "" && source != destination
+}
+
+// zoneText describes the zones of req for the ZONE column: a single zone if
+// both peers are in it, or the client's and server's zones if they differ.
+func zoneText(el *eventLog, req pkg.Stream) string {
+	source, destination := zones(req)
+	if source == destination {
+		return source
+	}
+	if source == "" {
+		source = "?"
+	}
+	if destination == "" {
+		destination = "?"
+	}
+	return source + "→" + destination
+}
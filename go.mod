@@ -3,10 +3,19 @@ module github.com/adleong/tapshark
 go 1.16
 
 require (
+	github.com/fortytw2/leaktest v1.3.0
 	github.com/gdamore/tcell/v2 v2.2.0
 	github.com/golang/protobuf v1.5.2
 	github.com/linkerd/linkerd2 v0.0.0-20220804180254-c3594bd373cb
 	github.com/rivo/tview v0.0.0-20210312174852-ae9464cc3598
 	github.com/sirupsen/logrus v1.9.0
 	github.com/spf13/cobra v1.5.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	google.golang.org/protobuf v1.28.1
+	k8s.io/api v0.24.3
+	k8s.io/apimachinery v0.24.3
+	k8s.io/client-go v0.24.3
 )
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/linkerd/linkerd2/pkg/protohttp"
+)
+
+// Errors from opening and reading a tap stream. They are wrapped with the
+// underlying error, so they should be matched with errors.Is.
+var (
+	// ErrUnauthorized is returned by Connect when the tap API rejects the
+	// caller's credentials or they may not tap the resource.
+	ErrUnauthorized = errors.New("not authorized to tap")
+	// ErrStreamEnded is sent by RecvEvents when the server ended the tap
+	// stream.
+	ErrStreamEnded = errors.New("tap stream ended")
+	// ErrStreamClosed is sent by RecvEvents when the stream's body was
+	// closed by the client, such as when the tap is stopped.
+	ErrStreamClosed = errors.New("tap stream closed")
+	// ErrConnectionLost is sent by RecvEvents when reading the stream
+	// failed, such as when the connection was reset.
+	ErrConnectionLost = errors.New("tap connection lost")
+	// ErrDecode is sent by RecvEvents when too many events in a row could
+	// not be decoded, and the stream is assumed to be corrupt.
+	ErrDecode = errors.New("failed to decode tap events")
+)
+
+// connectError wraps an error from opening a tap stream with
+// ErrUnauthorized if it was an authorization failure.
+func connectError(err error) error {
+	var httpErr protohttp.HTTPError
+	if errors.As(err, &httpErr) && (httpErr.Code == http.StatusUnauthorized || httpErr.Code == http.StatusForbidden) {
+		return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+	return err
+}
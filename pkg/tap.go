@@ -2,92 +2,235 @@ package pkg
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/linkerd/linkerd2/pkg/addr"
+	"github.com/linkerd/linkerd2/pkg/k8s"
 	"github.com/linkerd/linkerd2/pkg/protohttp"
-	"github.com/linkerd/linkerd2/viz/tap/pkg"
 	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	"github.com/linkerd/linkerd2/viz/tap/pkg"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
 )
 
 // Event collation logic copied from https://github.com/linkerd/linkerd2/blob/main/viz/cmd/top.go
 
 type (
 	Stream struct {
-		Event   *tapPb.TapEvent
-		ReqInit *tapPb.TapEvent_Http_RequestInit
-		RspInit *tapPb.TapEvent_Http_ResponseInit
-		RspEnd  *tapPb.TapEvent_Http_ResponseEnd
+		Event       *tapPb.TapEvent
+		ReqInit     *tapPb.TapEvent_Http_RequestInit
+		RspInit     *tapPb.TapEvent_Http_ResponseInit
+		RspEnd      *tapPb.TapEvent_Http_ResponseEnd
 		TimestampMs uint64
+		// Time is when the response ended, if known.
+		Time time.Time
+		// Seq numbers requests in the order they were received, from 1,
+		// so that those that completed in the same millisecond can still
+		// be ordered. It is 0 if unknown.
+		Seq uint64
+	}
+
+	// An EventSink receives each Stream once its response has ended.
+	EventSink interface {
+		Emit(Stream)
+	}
+
+	// ChanSink is an EventSink that sends each Stream on Done. If Stop is
+	// set, a Stream that is waiting to be received once it is closed is
+	// dropped, as with ProgressChanSink.
+	ChanSink struct {
+		Done chan<- Stream
+		Stop <-chan struct{}
+	}
+
+	// A ProgressSink is an EventSink that is also told about each Stream
+	// before it ends: once its request starts, with only Event and
+	// ReqInit set, and again once its response headers arrive.
+	ProgressSink interface {
+		EventSink
+		Progress(Stream)
+	}
+
+	// ProgressChanSink is a ProgressSink that sends each completed Stream
+	// on Done and each Stream in progress on InProgress. If Stop is set,
+	// a Stream that is waiting to be received once it is closed is
+	// dropped, so that nothing is left blocked when the receiver goes away.
+	ProgressChanSink struct {
+		Done       chan<- Stream
+		InProgress chan<- Stream
+		Stop       <-chan struct{}
 	}
 
-	streamID struct {
-		src    string
-		dst    string
-		stream uint64
+	// A StreamID identifies a request by the addresses of its peers and the
+	// id the proxy gave its stream. The id's Base is chosen at random by each
+	// proxy when it starts, so a request that is reported again after the
+	// tap reconnects has the same StreamID.
+	StreamID struct {
+		Source      string
+		Destination string
+		Base        uint32
+		Stream      uint64
 	}
 )
 
-func RecvEvents(tapByteStream *bufio.Reader, eventCh chan<- *tapPb.TapEvent, closing chan<- struct{}) {
+// maxDecodeErrors is the number of consecutive events that may fail to decode
+// before the stream is assumed to be corrupt and abandoned.
+const maxDecodeErrors = 10
+
+// Connect opens a tap stream for req. Authorization failures are wrapped with
+// ErrUnauthorized.
+func Connect(ctx context.Context, k8sAPI *k8s.KubernetesAPI, req *tapPb.TapByResourceRequest) (*bufio.Reader, io.ReadCloser, error) {
+	reader, body, err := pkg.Reader(ctx, k8sAPI, req)
+	if err != nil {
+		return nil, nil, connectError(err)
+	}
+	return reader, body, nil
+}
+
+// RecvEvents decodes events from tapByteStream and sends them on eventCh until
+// the stream ends, and then sends why it ended on closing: ErrStreamEnded,
+// ErrStreamClosed, ErrConnectionLost or ErrDecode. It returns without sending
+// anything if ctx is done while an event is waiting to be received.
+func RecvEvents(ctx context.Context, tapByteStream *bufio.Reader, eventCh chan<- *tapPb.TapEvent, closing chan<- error) {
+	decodeErrors := 0
 	for {
 		event := &tapPb.TapEvent{}
 		err := protohttp.FromByteStreamToProtocolBuffers(tapByteStream, event)
+		if errors.Is(err, proto.Error) && decodeErrors < maxDecodeErrors {
+			// Events are length prefixed, so the malformed event has been
+			// read in full and the stream is positioned at the next one.
+			decodeErrors++
+			log.Warnf("Skipping malformed tap event: %v", err)
+			continue
+		}
 		if err != nil {
-			if err == io.EOF {
-				fmt.Println("Tap stream terminated")
-			} else if !strings.HasSuffix(err.Error(), pkg.ErrClosedResponseBody) {
-				fmt.Println(err.Error())
+			switch {
+			case errors.Is(err, io.EOF):
+				log.Info("Tap stream terminated")
+				err = ErrStreamEnded
+			case strings.HasSuffix(err.Error(), pkg.ErrClosedResponseBody):
+				err = ErrStreamClosed
+			case errors.Is(err, proto.Error):
+				err = fmt.Errorf("%w: %v", ErrDecode, err)
+			default:
+				err = fmt.Errorf("%w: %v", ErrConnectionLost, err)
 			}
+			closing <- err
+			return
+		}
 
-			closing <- struct{}{}
+		decodeErrors = 0
+		select {
+		case eventCh <- event:
+		case <-ctx.Done():
 			return
 		}
+	}
+}
+
+// ID returns the identity of the request.
+func (s Stream) ID() StreamID {
+	return newStreamID(s.Event, s.ReqInit.GetId())
+}
+
+func newStreamID(event *tapPb.TapEvent, id *tapPb.TapEvent_Http_StreamId) StreamID {
+	return StreamID{
+		Source:      addr.PublicAddressToString(event.GetSource()),
+		Destination: addr.PublicAddressToString(event.GetDestination()),
+		Base:        id.GetBase(),
+		Stream:      id.GetStream(),
+	}
+}
+
+// Emit implements EventSink.
+func (c ChanSink) Emit(req Stream) {
+	select {
+	case c.Done <- req:
+	case <-c.Stop:
+	}
+}
 
-		eventCh <- event
+// Emit implements EventSink.
+func (c ProgressChanSink) Emit(req Stream) {
+	select {
+	case c.Done <- req:
+	case <-c.Stop:
 	}
 }
 
-func ProcessEvents(eventCh <-chan *tapPb.TapEvent, requestCh chan<- Stream, done <-chan struct{}) {
-	outstandingRequests := make(map[streamID]Stream)
+// Progress implements ProgressSink.
+func (c ProgressChanSink) Progress(req Stream) {
+	select {
+	case c.InProgress <- req:
+	case <-c.Stop:
+	}
+}
+
+// ProcessEvents pairs up the request and response events of each stream from
+// eventCh and emits the completed Stream to sink, until ctx is done. If sink
+// is a ProgressSink, it is also told about each Stream as it progresses. A
+// request whose StreamID is reused before it ends is emitted as soon as that
+// happens, without RspEnd.
+func ProcessEvents(ctx context.Context, eventCh <-chan *tapPb.TapEvent, sink EventSink) {
+	outstandingRequests := make(map[StreamID]Stream)
+	progress, _ := sink.(ProgressSink)
 
 	for {
 		select {
-		case <-done:
+		case <-ctx.Done():
 			return
 		case event := <-eventCh:
-			id := streamID{
-				src: addr.PublicAddressToString(event.GetSource()),
-				dst: addr.PublicAddressToString(event.GetDestination()),
-			}
 			switch ev := event.GetHttp().GetEvent().(type) {
 			case *tapPb.TapEvent_Http_RequestInit_:
-				id.stream = ev.RequestInit.GetId().Stream
+				id := newStreamID(event, ev.RequestInit.GetId())
+				// A stream ID that is still outstanding has been
+				// reused, so its earlier request will never end. It
+				// is emitted as it is, without a response end,
+				// rather than replaced and lost.
+				if prior, ok := outstandingRequests[id]; ok {
+					log.Warnf("Got RequestInit for outstanding stream: %v; emitting the earlier request as incomplete", id)
+					sink.Emit(prior)
+				}
 				outstandingRequests[id] = Stream{
 					Event:   event,
 					ReqInit: ev.RequestInit,
 				}
+				if progress != nil {
+					progress.Progress(outstandingRequests[id])
+				}
 
 			case *tapPb.TapEvent_Http_ResponseInit_:
-				id.stream = ev.ResponseInit.GetId().Stream
+				id := newStreamID(event, ev.ResponseInit.GetId())
 				if req, ok := outstandingRequests[id]; ok {
 					req.RspInit = ev.ResponseInit
 					outstandingRequests[id] = req
+					if progress != nil {
+						progress.Progress(req)
+					}
 				} else {
-					log.Warnf("Got ResponseInit for unknown stream: %s", id)
+					log.Warnf("Got ResponseInit for unknown stream: %v", id)
 				}
 
 			case *tapPb.TapEvent_Http_ResponseEnd_:
-				id.stream = ev.ResponseEnd.GetId().Stream
+				id := newStreamID(event, ev.ResponseEnd.GetId())
 				if req, ok := outstandingRequests[id]; ok {
 					req.RspEnd = ev.ResponseEnd
-					requestCh <- req
+					delete(outstandingRequests, id)
+					sink.Emit(req)
 				} else {
-					log.Warnf("Got ResponseEnd for unknown stream: %s", id)
+					log.Warnf("Got ResponseEnd for unknown stream: %v", id)
 				}
+
+			default:
+				// Events that this version of the tap API doesn't
+				// know about are skipped.
+				log.Debugf("Ignoring tap event of unknown type: %v", event)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
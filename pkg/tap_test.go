@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/fortytw2/leaktest"
+	"github.com/linkerd/linkerd2/pkg/protohttp"
+	tapPb "github.com/linkerd/linkerd2/viz/tap/gen/tap"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeSink records the Streams it is given.
+type fakeSink struct {
+	emitted  []Stream
+	progress []Stream
+}
+
+func (s *fakeSink) Emit(req Stream)     { s.emitted = append(s.emitted, req) }
+func (s *fakeSink) Progress(req Stream) { s.progress = append(s.progress, req) }
+
+func streamID(stream uint64) *tapPb.TapEvent_Http_StreamId {
+	return &tapPb.TapEvent_Http_StreamId{Base: 1, Stream: stream}
+}
+
+func requestInit(stream uint64) *tapPb.TapEvent {
+	return httpEvent(&tapPb.TapEvent_Http{Event: &tapPb.TapEvent_Http_RequestInit_{
+		RequestInit: &tapPb.TapEvent_Http_RequestInit{Id: streamID(stream)},
+	}})
+}
+
+func responseInit(stream uint64) *tapPb.TapEvent {
+	return httpEvent(&tapPb.TapEvent_Http{Event: &tapPb.TapEvent_Http_ResponseInit_{
+		ResponseInit: &tapPb.TapEvent_Http_ResponseInit{Id: streamID(stream), HttpStatus: 200},
+	}})
+}
+
+func responseEnd(stream uint64) *tapPb.TapEvent {
+	return httpEvent(&tapPb.TapEvent_Http{Event: &tapPb.TapEvent_Http_ResponseEnd_{
+		ResponseEnd: &tapPb.TapEvent_Http_ResponseEnd{Id: streamID(stream)},
+	}})
+}
+
+func httpEvent(http *tapPb.TapEvent_Http) *tapPb.TapEvent {
+	return &tapPb.TapEvent{Event: &tapPb.TapEvent_Http_{Http: http}}
+}
+
+// processEvents runs ProcessEvents over events and returns what it gave sink.
+func processEvents(events []*tapPb.TapEvent) *fakeSink {
+	ctx, cancel := context.WithCancel(context.Background())
+	eventCh := make(chan *tapPb.TapEvent)
+	sink := &fakeSink{}
+	done := make(chan struct{})
+	go func() {
+		ProcessEvents(ctx, eventCh, sink)
+		close(done)
+	}()
+	for _, event := range events {
+		eventCh <- event
+	}
+	// An event of no known type is only received once the last of events
+	// has been handled.
+	eventCh <- &tapPb.TapEvent{}
+	cancel()
+	<-done
+	return sink
+}
+
+func TestProcessEvents(t *testing.T) {
+	// Each emitted Stream is described by the number of its stream and
+	// whether it has a response and an end.
+	type emitted struct {
+		stream           uint64
+		response, ending bool
+	}
+	testCases := []struct {
+		name     string
+		events   []*tapPb.TapEvent
+		emitted  []emitted
+		progress int
+	}{
+		{
+			name:     "complete request",
+			events:   []*tapPb.TapEvent{requestInit(1), responseInit(1), responseEnd(1)},
+			emitted:  []emitted{{1, true, true}},
+			progress: 2,
+		},
+		{
+			name:     "interleaved requests",
+			events:   []*tapPb.TapEvent{requestInit(1), requestInit(2), responseEnd(2), responseInit(1), responseEnd(1)},
+			emitted:  []emitted{{2, false, true}, {1, true, true}},
+			progress: 3,
+		},
+		{
+			name:     "unfinished request",
+			events:   []*tapPb.TapEvent{requestInit(1), responseInit(1)},
+			progress: 2,
+		},
+		{
+			name:     "reused stream ID",
+			events:   []*tapPb.TapEvent{requestInit(1), requestInit(1), responseEnd(1)},
+			emitted:  []emitted{{1, false, false}, {1, false, true}},
+			progress: 2,
+		},
+		{
+			name:     "stream ID reused after it ended",
+			events:   []*tapPb.TapEvent{requestInit(1), responseEnd(1), requestInit(1), responseEnd(1)},
+			emitted:  []emitted{{1, false, true}, {1, false, true}},
+			progress: 2,
+		},
+		{
+			name:     "repeated response end",
+			events:   []*tapPb.TapEvent{requestInit(1), responseEnd(1), responseEnd(1)},
+			emitted:  []emitted{{1, false, true}},
+			progress: 1,
+		},
+		{
+			name:   "response for unknown stream",
+			events: []*tapPb.TapEvent{responseInit(1), responseEnd(1)},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sink := processEvents(tc.events)
+			if len(sink.emitted) != len(tc.emitted) {
+				t.Fatalf("expected %d streams to be emitted, got %d", len(tc.emitted), len(sink.emitted))
+			}
+			for i, expected := range tc.emitted {
+				req := sink.emitted[i]
+				actual := emitted{req.ReqInit.GetId().GetStream(), req.RspInit != nil, req.RspEnd != nil}
+				if actual != expected {
+					t.Errorf("expected stream %d to be %+v, got %+v", i, expected, actual)
+				}
+			}
+			if len(sink.progress) != tc.progress {
+				t.Errorf("expected %d progress updates, got %d", tc.progress, len(sink.progress))
+			}
+		})
+	}
+}
+
+// TestStopWithoutReceiver checks that RecvEvents and ProcessEvents return
+// once their context is done, even while nothing is receiving what they send,
+// with either sink.
+func TestStopWithoutReceiver(t *testing.T) {
+	// A goroutine that has not run yet is ignored by leaktest, so let those
+	// started by package init, such as the opencensus view worker, run
+	// before it takes its snapshot.
+	runtime.Gosched()
+
+	for _, tc := range []struct {
+		name string
+		sink func(stop <-chan struct{}) EventSink
+		// events are sent to ProcessEvents, after which it is blocked
+		// sending to the sink.
+		events []*tapPb.TapEvent
+	}{
+		// A reused stream ID has its earlier request emitted.
+		{"ChanSink", func(stop <-chan struct{}) EventSink {
+			return ChanSink{Done: make(chan Stream), Stop: stop}
+		}, []*tapPb.TapEvent{requestInit(1), requestInit(1)}},
+		// The sink is told as soon as a request starts.
+		{"ProgressChanSink", func(stop <-chan struct{}) EventSink {
+			return ProgressChanSink{Done: make(chan Stream), InProgress: make(chan Stream), Stop: stop}
+		}, []*tapPb.TapEvent{requestInit(1)}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			defer leaktest.Check(t)()
+
+			msg, err := proto.Marshal(requestInit(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream := bufio.NewReader(bytes.NewReader(protohttp.SerializeAsPayload(msg)))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			// Neither channel is ever received from.
+			eventCh := make(chan *tapPb.TapEvent)
+			closing := make(chan error, 1)
+			go RecvEvents(ctx, stream, eventCh, closing)
+
+			processed := make(chan *tapPb.TapEvent)
+			go ProcessEvents(ctx, processed, tc.sink(ctx.Done()))
+			for _, event := range tc.events {
+				processed <- event
+			}
+
+			cancel()
+		})
+	}
+}